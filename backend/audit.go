@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------- Audit log ----------------
+// Every admin action that changes posts, caches or media is appended as one JSON
+// line to audit.jsonl on the data volume (which /data/ does not serve). The
+// file is never rewritten; GET /api/admin/audit reads it back, newest first.
+
+// Audit actions; the part before the dot groups them for filtering
+const (
+	auditPostCreate       = "post.create"
+	auditPostEdit         = "post.edit"
+	auditPostImage        = "post.image"
+	auditPostStatus       = "post.status"
+	auditPostRestore      = "post.restore"
+	auditPostAliasRemove  = "post.alias-remove"
+	auditPostDelete       = "post.delete"
+	auditCachePurge       = "cache.purge"
+	auditClubRefresh      = "cache.club-refresh"
+	auditVideosRefresh    = "cache.videos-refresh"
+	auditMediaUpload      = "media.upload"
+	auditMediaEdit        = "media.edit"
+	auditMediaDelete      = "media.delete"
+	auditMediaAlbum       = "media.album"
+	auditMediaAlbumDelete = "media.album-delete"
+)
+
+// auditMaxLimit caps the entries one audit request returns
+const auditMaxLimit = 1000
+
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user,omitempty"`
+	IP     string    `json:"ip"`
+	Action string    `json:"action"`
+	IDs    []string  `json:"ids,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+type auditLog struct {
+	mu   sync.Mutex
+	path string // empty: auditPath()
+}
+
+var audit auditLog
+
+func auditPath() string {
+	if p := os.Getenv("AUDIT_PATH"); p != "" {
+		return p
+	}
+	// Default: next to club.json on the data volume
+	return filepath.Join(filepath.Dir(dataPath()), "audit.jsonl")
+}
+
+func (a *auditLog) file() string {
+	if a.path != "" {
+		return a.path
+	}
+	return auditPath()
+}
+
+// append writes one entry at the end of the log
+func (a *auditLog) append(e auditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	path := a.file()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// record logs an action of the request's user; a failure to write is logged
+// but does not fail the action itself
+func (a *auditLog) record(r *http.Request, action, detail string, ids ...string) {
+	e := auditEntry{
+		Time:   time.Now().UTC(),
+		User:   requestUser(r),
+		IP:     clientIP(r),
+		Action: action,
+		IDs:    ids,
+		Detail: detail,
+	}
+	if err := a.append(e); err != nil {
+		log.Printf("audit %s %v: %v", action, ids, err)
+	}
+}
+
+// auditQuery filters the log; Action matches whole actions ("post.delete")
+// or their group ("post")
+type auditQuery struct {
+	User   string
+	Action string
+	ID     string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+func (q auditQuery) match(e auditEntry) bool {
+	if q.User != "" && !strings.EqualFold(e.User, q.User) {
+		return false
+	}
+	if q.Action != "" && e.Action != q.Action && !strings.HasPrefix(e.Action, q.Action+".") {
+		return false
+	}
+	if q.ID != "" {
+		found := false
+		for _, id := range e.IDs {
+			found = found || id == q.ID
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Time.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// query returns the matching entries, newest first, up to q.Limit (0 = all),
+// and how many entries matched in total
+func (a *auditLog) query(q auditQuery) ([]auditEntry, int, error) {
+	a.mu.Lock()
+	f, err := os.Open(a.file())
+	a.mu.Unlock()
+	if os.IsNotExist(err) {
+		return []auditEntry{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	var out []auditEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var e auditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			log.Printf("audit line %d: %v", line, err)
+			continue
+		}
+		if q.match(e) {
+			out = append(out, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, 0, err
+	}
+	total := len(out)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	if out == nil {
+		out = []auditEntry{}
+	}
+	return out, total, nil
+}
+
+// auditHandler serves GET /api/admin/audit?user=&action=&id=&since=&until=&limit=
+// to admins; since and until take RFC 3339 times or plain dates
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, roleAdmin) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	v := r.URL.Query()
+	q := auditQuery{User: v.Get("user"), Action: v.Get("action"), ID: v.Get("id"), Limit: 100}
+	var err error
+	if s := v.Get("since"); s != "" {
+		if q.Since, err = parseBlogDate(s); err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := v.Get("until"); s != "" {
+		if q.Until, err = parseBlogDate(s); err != nil {
+			http.Error(w, "invalid until", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := v.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		q.Limit = min(q.Limit, auditMaxLimit)
+	}
+	entries, total, err := audit.query(q)
+	if err != nil {
+		log.Printf("audit query: %v", err)
+		http.Error(w, "cannot read audit log", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]any{"items": entries, "total": total})
+}
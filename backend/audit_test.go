@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestAuditLogAppendAndQuery records actions through requests and reads them
+// back with filters, newest first.
+func TestAuditLogAppendAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	al := auditLog{path: path}
+	req := func(ip string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/api/blog/edit", nil)
+		r.RemoteAddr = ip + ":1234"
+		return r
+	}
+	al.record(req("198.51.100.4"), auditPostCreate, "Výhra v derby", "0007")
+	al.record(req("198.51.100.4"), auditPostImage, "derby.jpg", "0007")
+	al.record(req("198.51.100.9"), auditPostDelete, "Starý článek", "0003")
+	al.record(req("198.51.100.9"), auditCachePurge, "club.json")
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("audit file: %v %v", info, err)
+	}
+	all, total, err := al.query(auditQuery{})
+	if err != nil || total != 4 || all[0].Action != auditCachePurge || all[3].Action != auditPostCreate {
+		t.Fatalf("all entries: %+v %d %v", all, total, err)
+	}
+	if all[2].IP != "198.51.100.4" || all[2].Detail != "derby.jpg" {
+		t.Errorf("entry fields: %+v", all[2])
+	}
+	if got, total, _ := al.query(auditQuery{Action: "post"}); total != 3 || len(got) != 3 {
+		t.Errorf("action group post: %d", total)
+	}
+	if got, _, _ := al.query(auditQuery{ID: "0007", Limit: 1}); len(got) != 1 || got[0].Action != auditPostImage {
+		t.Errorf("id filter with limit: %+v", got)
+	}
+	if _, total, _ := al.query(auditQuery{Since: time.Now().Add(time.Hour)}); total != 0 {
+		t.Errorf("since filter kept %d entries", total)
+	}
+
+	// Appending never rewrites earlier lines
+	before, _ := os.ReadFile(path)
+	al.record(req("198.51.100.9"), auditVideosRefresh, "")
+	after, _ := os.ReadFile(path)
+	if string(after[:len(before)]) != string(before) {
+		t.Error("earlier entries changed")
+	}
+	var last auditEntry
+	if err := json.Unmarshal(after[len(before):], &last); err != nil || last.Action != auditVideosRefresh {
+		t.Errorf("last line: %s %v", after[len(before):], err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ---------------- Cache control (admin) ----------------
+// The FACR club data and the YouTube list are refreshed by the schedulers;
+// editors can force it here. The public /data/club.json and /api/videos/latest
+// endpoints only read.
+//
+//	GET    /api/admin/cache                 state of both caches
+//	POST   /api/admin/cache/club/refresh    refetch the club data, keeping the old data on failure
+//	DELETE /api/admin/cache/club            drop club.json and the in-memory copy, then refetch
+//	POST   /api/admin/cache/videos/refresh  refetch the YouTube list
+
+var clubRefreshLimiter rateLimiter
+
+// cacheRefreshResult reports one refresh to the admin
+type cacheRefreshResult struct {
+	Cache      string         `json:"cache"`
+	OK         bool           `json:"ok"`
+	Error      string         `json:"error,omitempty"`
+	DurationMS int64          `json:"duration_ms"`
+	Source     string         `json:"source,omitempty"` // club: primary or fallback; videos: primary or kept
+	Counts     map[string]int `json:"counts"`
+	FetchedAt  time.Time      `json:"fetched_at"`
+}
+
+// clubCounts summarizes the cached club data
+func clubCounts() (map[string]int, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	matches, rows := 0, 0
+	for _, comp := range c.data.ClubDetail.Competitions {
+		matches += len(comp.Matches)
+	}
+	for _, comp := range c.data.ClubTable.Competitions {
+		rows += len(comp.Table.Overall)
+	}
+	return map[string]int{
+		"competitions": len(c.data.ClubDetail.Competitions),
+		"matches":      matches,
+		"table_rows":   rows,
+	}, c.data.FetchedAt
+}
+
+// videoCounts summarizes the cached video list
+func videoCounts() (map[string]int, time.Time) {
+	vc.mu.RLock()
+	defer vc.mu.RUnlock()
+	return map[string]int{"videos": len(vc.data.Items)}, vc.data.FetchedAt
+}
+
+// purgeClubCache removes the persisted and in-memory club data
+func purgeClubCache() {
+	_ = os.Remove(dataPath())
+	c.mu.Lock()
+	c.data = Combined{}
+	c.mu.Unlock()
+}
+
+// cacheHandler serves /api/admin/cache and its actions
+func cacheHandler(w http.ResponseWriter, r *http.Request) {
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/cache"), "/")
+	role := roleEditor
+	if r.Method == http.MethodGet {
+		role = roleViewer
+	}
+	if !requireRole(w, r, role) {
+		return
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var res cacheRefreshResult
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		club, clubFetched := clubCounts()
+		videos, videosFetched := videoCounts()
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"club":   map[string]any{"counts": club, "fetched_at": clubFetched},
+			"videos": map[string]any{"counts": videos, "fetched_at": videosFetched},
+		})
+		return
+	case action == "club/refresh" && r.Method == http.MethodPost, action == "club" && r.Method == http.MethodDelete:
+		if !clubRefreshLimiter.Allow(time.Now(), 5, time.Minute) {
+			http.Error(w, "rate limit: max 5 refresh per minute", http.StatusTooManyRequests)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			purgeClubCache()
+		}
+		start := time.Now()
+		source, err := refresh(r.Context())
+		res = cacheRefreshResult{Cache: "club", Source: source, DurationMS: time.Since(start).Milliseconds()}
+		res.Counts, res.FetchedAt = clubCounts()
+		if err != nil {
+			log.Printf("manual club refresh: %v", err)
+			res.Error = err.Error()
+		}
+		detail := fmt.Sprintf("source %s, %d ms", source, res.DurationMS)
+		if err != nil {
+			detail = "failed: " + err.Error()
+		}
+		if r.Method == http.MethodDelete {
+			audit.record(r, auditCachePurge, "club.json; "+detail)
+		} else {
+			audit.record(r, auditClubRefresh, detail)
+		}
+	case action == "videos/refresh" && r.Method == http.MethodPost:
+		if !videosPostLimiter.Allow(time.Now(), 5, time.Minute) {
+			http.Error(w, "rate limit: max 5 refresh per minute", http.StatusTooManyRequests)
+			return
+		}
+		start := time.Now()
+		source, err := refreshVideos(r.Context())
+		res = cacheRefreshResult{Cache: "videos", Source: source, DurationMS: time.Since(start).Milliseconds()}
+		res.Counts, res.FetchedAt = videoCounts()
+		if err != nil {
+			log.Printf("manual refreshVideos error: %v", err)
+			res.Error = err.Error()
+			audit.record(r, auditVideosRefresh, "failed: "+err.Error())
+		} else {
+			audit.record(r, auditVideosRefresh, fmt.Sprintf("source %s, %d ms", source, res.DurationMS))
+		}
+	case action == "" || action == "club" || action == "club/refresh" || action == "videos/refresh":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	res.OK = res.Error == ""
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if !res.OK {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
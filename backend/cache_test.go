@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// TestCacheHandlerAccess checks that cache control needs an account and that
+// the state endpoint reports the cached counts.
+func TestCacheHandlerAccess(t *testing.T) {
+	if err := users.open(filepath.Join(t.TempDir(), "users.json")); err != nil {
+		t.Fatal(err)
+	}
+	defer users.open(filepath.Join(t.TempDir(), "none.json"))
+	if _, err := users.set("trener", roleViewer, "heslo-trenera", true); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ADMIN_BASIC_AUTH", "1")
+
+	serve := func(method, path string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if auth {
+			req.SetBasicAuth("trener", "heslo-trenera")
+		}
+		rec := httptest.NewRecorder()
+		cacheHandler(rec, req)
+		return rec
+	}
+	if rec := serve(http.MethodDelete, "/api/admin/cache/club", false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous purge: %d", rec.Code)
+	}
+	if rec := serve(http.MethodPost, "/api/admin/cache/videos/refresh", true); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer refresh: %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/api/admin/cache/nothing", true); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown action: %d", rec.Code)
+	}
+
+	vc.mu.Lock()
+	saved := vc.data.Items
+	vc.data.Items = []YTVideo{{VideoID: "a"}, {VideoID: "b"}}
+	vc.mu.Unlock()
+	defer func() {
+		vc.mu.Lock()
+		vc.data.Items = saved
+		vc.mu.Unlock()
+	}()
+	rec := serve(http.MethodGet, "/api/admin/cache", true)
+	var state struct {
+		Videos struct {
+			Counts map[string]int `json:"counts"`
+		} `json:"videos"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil || state.Videos.Counts["videos"] != 2 {
+		t.Errorf("cache state: %d %+v %v", rec.Code, state, err)
+	}
+}
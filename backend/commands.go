@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ---------------- Maintenance subcommands ----------------
+// `server <command>` runs a one-off task against the same STATIC_PATH and
+// data paths the server uses, then exits.
+
+// runCommand executes a subcommand and returns the process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "import-blogs":
+		// Opening the store imports every page that has no record yet
+		if err := posts.open(blogDirFor(staticPath()), postsPath()); err != nil {
+			fmt.Fprintf(os.Stderr, "import-blogs: %v\n", err)
+			return 1
+		}
+		fmt.Printf("blog store holds %d posts (%s)\n", len(posts.list(0)), postsPath())
+		return 0
+	case "render-blogs":
+		if err := posts.open(blogDirFor(staticPath()), postsPath()); err != nil {
+			fmt.Fprintf(os.Stderr, "render-blogs: %v\n", err)
+			return 1
+		}
+		n, err := posts.renderAll()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "render-blogs: %v\n", err)
+			return 1
+		}
+		fmt.Printf("rendered %d posts\n", n)
+		return 0
+	case "backfill-meta":
+		if err := posts.open(blogDirFor(staticPath()), postsPath()); err != nil {
+			fmt.Fprintf(os.Stderr, "backfill-meta: %v\n", err)
+			return 1
+		}
+		n, err := posts.backfill()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backfill-meta: %v\n", err)
+			return 1
+		}
+		fmt.Printf("added social and structured data tags to %d posts (site URL %s)\n", n, siteBaseURL())
+		return 0
+	case "image-variants":
+		if err := posts.open(blogDirFor(staticPath()), postsPath()); err != nil {
+			fmt.Fprintf(os.Stderr, "image-variants: %v\n", err)
+			return 1
+		}
+		n, err := posts.regenerateImageVariants()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "image-variants: %v\n", err)
+			return 1
+		}
+		fmt.Printf("wrote image variants for %d posts\n", n)
+		return 0
+	case "user":
+		return runUserCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		fmt.Fprintln(os.Stderr, "usage: server [command]")
+		fmt.Fprintln(os.Stderr, "  (no command)   run the web server")
+		fmt.Fprintln(os.Stderr, "  import-blogs   import blog/*.html pages into the post store")
+		fmt.Fprintln(os.Stderr, "  render-blogs   re-render every post page from the layout")
+		fmt.Fprintln(os.Stderr, "  backfill-meta  render hand-made and outdated post pages with Open Graph,")
+		fmt.Fprintln(os.Stderr, "                 Twitter card and JSON-LD tags (uses SITE_URL)")
+		fmt.Fprintln(os.Stderr, "  image-variants cut every post image again from its kept upload and write")
+		fmt.Fprintln(os.Stderr, "                 its JPEG and WebP sizes")
+		fmt.Fprintln(os.Stderr, "  user ...       manage admin accounts (run `server user` for details)")
+		return 2
+	}
+}
+
+// runUserCommand manages admin accounts in users.json
+func runUserCommand(args []string) int {
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "usage: server user <command>")
+		fmt.Fprintln(os.Stderr, "  list                      list accounts and their roles")
+		fmt.Fprintln(os.Stderr, "  add <name> <role>         create an account (role: viewer, editor, admin)")
+		fmt.Fprintln(os.Stderr, "  reset <name>              set a new password")
+		fmt.Fprintln(os.Stderr, "  role <name> <role>        change the role of an account")
+		fmt.Fprintln(os.Stderr, "  remove <name>             delete an account")
+		fmt.Fprintln(os.Stderr, "Passwords are read from the first line of standard input.")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+	if err := users.open(usersPath()); err != nil {
+		fmt.Fprintf(os.Stderr, "user: %v\n", err)
+		return 1
+	}
+	var err error
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		for _, u := range users.list() {
+			fmt.Printf("%-32s %-8s updated %s\n", u.Name, u.Role, u.UpdatedAt.Format("2006-01-02 15:04"))
+		}
+		return 0
+	case args[0] == "add" && len(args) == 3:
+		_, err = users.set(args[1], args[2], readPassword(), true)
+	case args[0] == "reset" && len(args) == 2:
+		if _, ok := users.get(args[1]); !ok {
+			err = fmt.Errorf("no user %q", args[1])
+			break
+		}
+		_, err = users.set(args[1], "", readPassword(), false)
+	case args[0] == "role" && len(args) == 3:
+		err = users.setRole(args[1], args[2])
+	case args[0] == "remove" && len(args) == 2:
+		err = users.remove(args[1])
+	default:
+		return usage()
+	}
+	if os.IsNotExist(err) {
+		err = fmt.Errorf("no user %q", args[1])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "user %s: %v\n", args[0], err)
+		return 1
+	}
+	fmt.Printf("user %s: done (%s)\n", args[0], usersPath())
+	return 0
+}
+
+// readPassword reads a password from the first line of standard input
+func readPassword() string {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ---------------- CORS ----------------
+// Public read endpoints (post lists, search, feeds, match and video data) may
+// be read by any origin. Everything else under /api/ and /admin/ only answers
+// the origins listed in CORS_ALLOWED_ORIGINS (comma-separated; default: the
+// SITE_URL origin), with credentials, e.g. for an admin on a subdomain.
+// Preflight requests are answered here for every path.
+
+// corsPublicPaths are read-only and open to every origin; entries ending in
+// "/" match their whole subtree
+var corsPublicPaths = []string{
+	"/healthz", "/data/", "/api/blog/latest", "/api/blog/categories",
+	"/api/blog/search", "/api/blog/resolve", "/api/videos/latest",
+	"/feed.xml", "/atom.xml", "/feed/", "/sitemap.xml", "/sitemaps/", "/robots.txt",
+}
+
+const (
+	corsPublicMethods = "GET, HEAD, OPTIONS"
+	corsAdminMethods  = "GET, POST, DELETE, OPTIONS"
+	corsAdminHeaders  = "Content-Type, " + csrfHeader
+	corsMaxAge        = "600"
+)
+
+type corsPolicy struct {
+	origins map[string]bool // allowed origins of the admin API
+}
+
+// loadCORSPolicy reads CORS_ALLOWED_ORIGINS
+func loadCORSPolicy() corsPolicy {
+	list := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if list == "" {
+		list = siteBaseURL()
+	}
+	p := corsPolicy{origins: make(map[string]bool)}
+	for _, o := range strings.Split(list, ",") {
+		if o = normalizeOrigin(o); o != "" {
+			p.origins[o] = true
+		}
+	}
+	return p
+}
+
+// normalizeOrigin reduces an origin or URL to its lowercase scheme://host[:port]
+func normalizeOrigin(o string) string {
+	u, err := url.Parse(strings.TrimSpace(o))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// corsPublic reports whether path is a public read endpoint
+func corsPublic(path string) bool {
+	for _, p := range corsPublicPaths {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsAdmin reports whether path belongs to the admin API or pages
+func corsAdmin(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
+
+// wrap adds the CORS headers of the path's policy and answers preflights
+func (p corsPolicy) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != ""
+		h := w.Header()
+		switch {
+		case corsPublic(r.URL.Path):
+			h.Set("Access-Control-Allow-Origin", "*")
+			if preflight {
+				h.Set("Access-Control-Allow-Methods", corsPublicMethods)
+				h.Set("Access-Control-Allow-Headers", "Content-Type")
+				h.Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		case corsAdmin(r.URL.Path):
+			h.Add("Vary", "Origin")
+			allowed := origin != "" && p.origins[normalizeOrigin(origin)]
+			if allowed {
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if preflight {
+				if !allowed {
+					http.Error(w, "origin not allowed", http.StatusForbidden)
+					return
+				}
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Methods", corsAdminMethods)
+				h.Set("Access-Control-Allow-Headers", corsAdminHeaders)
+				h.Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
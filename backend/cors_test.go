@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCORSPolicy checks the wildcard on public reads, the origin list on the
+// admin API and preflights answered before any handler.
+func TestCORSPolicy(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://www.bizoniuh.cz, https://Admin.Bizoniuh.cz/")
+	reached := false
+	h := loadCORSPolicy().wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	do := func(method, path, origin string) *httptest.ResponseRecorder {
+		reached = false
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/blog/latest", "https://example.org")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || !reached {
+		t.Errorf("public read: %v", rec.Header())
+	}
+	rec = do(http.MethodOptions, "/feed/zapasy.xml", "https://example.org")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Methods") != corsPublicMethods || reached {
+		t.Errorf("public preflight: %d %v", rec.Code, rec.Header())
+	}
+
+	rec = do(http.MethodPost, "/api/blog/edit", "https://evil.example")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Vary") != "Origin" {
+		t.Errorf("foreign origin on admin API: %v", rec.Header())
+	}
+	rec = do(http.MethodOptions, "/api/blog/edit", "https://evil.example")
+	if rec.Code != http.StatusForbidden || reached {
+		t.Errorf("foreign preflight: %d", rec.Code)
+	}
+	rec = do(http.MethodOptions, "/api/blog/edit", "https://admin.bizoniuh.cz")
+	if rec.Code != http.StatusNoContent || reached ||
+		rec.Header().Get("Access-Control-Allow-Origin") != "https://admin.bizoniuh.cz" ||
+		rec.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+		rec.Header().Get("Access-Control-Allow-Headers") != corsAdminHeaders {
+		t.Errorf("allowed preflight: %d %v", rec.Code, rec.Header())
+	}
+
+	// Static pages get no CORS headers at all
+	rec = do(http.MethodGet, "/index.html", "https://example.org")
+	if len(rec.Header().Values("Access-Control-Allow-Origin")) != 0 {
+		t.Errorf("static page: %v", rec.Header())
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"strings"
+	"time"
+)
+
+// ---------------- Upload metadata (EXIF) ----------------
+// Phones store portrait photos sideways with an EXIF Orientation tag, which
+// image.Decode ignores, and put the capture time, camera and often the GPS
+// position into the same block. Uploads are read here before decoding:
+// the image is turned upright, the capture date and camera model go into the
+// post record (imageMeta, never into served files), and the kept original
+// loses all metadata except its orientation.
+//
+// The served PNG and its JPEG/WebP variants are encoded from pixels only, so
+// they carry no metadata at all.
+
+// imageMeta is what the post record keeps from the metadata of its upload
+type imageMeta struct {
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+	Camera     string     `json:"camera,omitempty"`
+}
+
+// exifInfo is the part of an EXIF block the upload path reads
+type exifInfo struct {
+	Orientation int // 1..8; 0 when missing
+	CapturedAt  time.Time
+	Make        string
+	Model       string
+	HasGPS      bool
+}
+
+// meta returns the fields kept in the post record, or nil
+func (e exifInfo) meta() *imageMeta {
+	camera := e.Model
+	// Models usually repeat the make ("Canon EOS 80D"); add it when they do not
+	if e.Make != "" && !strings.HasPrefix(strings.ToLower(camera), strings.ToLower(e.Make)) {
+		camera = strings.TrimSpace(e.Make + " " + camera)
+	}
+	if camera == "" && e.CapturedAt.IsZero() {
+		return nil
+	}
+	m := &imageMeta{Camera: camera}
+	if !e.CapturedAt.IsZero() {
+		t := e.CapturedAt
+		m.CapturedAt = &t
+	}
+	return m
+}
+
+// EXIF tags read by readEXIF
+const (
+	exifTagMake             = 0x010f
+	exifTagModel            = 0x0110
+	exifTagOrientation      = 0x0112
+	exifTagDateTime         = 0x0132
+	exifTagExifIFD          = 0x8769
+	exifTagGPSIFD           = 0x8825
+	exifTagDateTimeOriginal = 0x9003
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// exifBlock returns the TIFF-structured EXIF data of a JPEG (APP1) or PNG
+// (eXIf chunk), or nil
+func exifBlock(b []byte) []byte {
+	if bytes.HasPrefix(b, pngSignature) {
+		var out []byte
+		eachPNGChunk(b, func(typ string, data, _ []byte) {
+			if typ == "eXIf" && out == nil {
+				out = data
+			}
+		})
+		return out
+	}
+	var out []byte
+	eachJPEGSegment(b, func(marker byte, seg []byte) {
+		if marker == 0xe1 && out == nil && bytes.HasPrefix(seg[4:], []byte("Exif\x00\x00")) {
+			out = seg[10:]
+		}
+	})
+	return out
+}
+
+// readEXIF parses the EXIF block of an upload; malformed or missing data
+// yields the zero value
+func readEXIF(b []byte) exifInfo {
+	var info exifInfo
+	tiff := exifBlock(b)
+	if len(tiff) < 8 {
+		return info
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return info
+	}
+	if bo.Uint16(tiff[2:]) != 42 {
+		return info
+	}
+	var dateTime, dateOriginal string
+	readIFD := func(off uint32, fn func(tag, typ uint16, count uint32, val []byte)) {
+		if off < 8 || int(off)+2 > len(tiff) {
+			return
+		}
+		n := int(bo.Uint16(tiff[off:]))
+		for i := 0; i < n; i++ {
+			e := int(off) + 2 + i*12
+			if e+12 > len(tiff) {
+				return
+			}
+			fn(bo.Uint16(tiff[e:]), bo.Uint16(tiff[e+2:]), bo.Uint32(tiff[e+4:]), tiff[e+8:e+12])
+		}
+	}
+	ascii := func(count uint32, val []byte) string {
+		var s []byte
+		if count > 4 {
+			off := bo.Uint32(val)
+			if uint64(off)+uint64(count) > uint64(len(tiff)) {
+				return ""
+			}
+			s = tiff[off : off+count]
+		} else {
+			s = val[:count]
+		}
+		return strings.TrimSpace(strings.TrimRight(string(s), "\x00"))
+	}
+	readIFD(bo.Uint32(tiff[4:]), func(tag, typ uint16, count uint32, val []byte) {
+		switch {
+		case tag == exifTagOrientation && typ == 3:
+			if o := int(bo.Uint16(val)); o >= 1 && o <= 8 {
+				info.Orientation = o
+			}
+		case tag == exifTagMake && typ == 2:
+			info.Make = ascii(count, val)
+		case tag == exifTagModel && typ == 2:
+			info.Model = ascii(count, val)
+		case tag == exifTagDateTime && typ == 2:
+			dateTime = ascii(count, val)
+		case tag == exifTagGPSIFD:
+			info.HasGPS = true
+		case tag == exifTagExifIFD && typ == 4:
+			readIFD(bo.Uint32(val), func(tag, typ uint16, count uint32, val []byte) {
+				if tag == exifTagDateTimeOriginal && typ == 2 {
+					dateOriginal = ascii(count, val)
+				}
+			})
+		}
+	})
+	// EXIF times have no zone; cameras are set to the local time
+	for _, s := range []string{dateOriginal, dateTime} {
+		if t, err := time.ParseInLocation("2006:01:02 15:04:05", s, pragueLocation()); err == nil {
+			info.CapturedAt = t
+			break
+		}
+	}
+	return info
+}
+
+// orientImage turns img upright according to an EXIF orientation
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirror
+				sx, sy = w-1-x, y
+			case 3: // turn 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip
+				sx, sy = x, h-1-y
+			case 5: // mirror and turn 90° counter-clockwise (transpose)
+				sx, sy = y, x
+			case 6: // turn 90° clockwise
+				sx, sy = y, h-1-x
+			case 7: // mirror and turn 90° clockwise (transverse)
+				sx, sy = w-1-y, h-1-x
+			case 8: // turn 90° counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+	return dst
+}
+
+// stripImageMetadata returns a JPEG or PNG upload without metadata segments
+// or chunks; only the orientation is written back, so the result still
+// decodes upright. Other data is returned unchanged.
+func stripImageMetadata(b []byte, orientation int) []byte {
+	if bytes.HasPrefix(b, pngSignature) {
+		var out bytes.Buffer
+		out.Write(pngSignature)
+		eachPNGChunk(b, func(typ string, data, raw []byte) {
+			// Critical chunks (upper case) and transparency stay; text, time,
+			// EXIF and the other ancillary chunks go
+			if typ[0] >= 'A' && typ[0] <= 'Z' || typ == "tRNS" {
+				if typ == "IDAT" && orientation > 1 {
+					writePNGChunk(&out, "eXIf", orientationEXIF(orientation))
+					orientation = 0
+				}
+				out.Write(raw)
+			}
+		})
+		return out.Bytes()
+	}
+	if len(b) < 2 || b[0] != 0xff || b[1] != 0xd8 {
+		return b
+	}
+	var out bytes.Buffer
+	out.Write(b[:2])
+	writeOrientation := func() {
+		if orientation <= 1 {
+			return
+		}
+		tiff := orientationEXIF(orientation)
+		seg := []byte{0xff, 0xe1, 0, 0}
+		binary.BigEndian.PutUint16(seg[2:], uint16(8+len(tiff)))
+		seg = append(seg, "Exif\x00\x00"...)
+		out.Write(append(seg, tiff...))
+		orientation = 0
+	}
+	end := eachJPEGSegment(b, func(marker byte, seg []byte) {
+		// APP0 (JFIF) describes the pixels; APP1..APP15 and comments are
+		// metadata (EXIF, XMP, ICC profiles, IPTC, ...)
+		if marker >= 0xe1 && marker <= 0xef || marker == 0xfe {
+			return
+		}
+		if marker != 0xe0 {
+			writeOrientation()
+		}
+		out.Write(seg)
+	})
+	writeOrientation()
+	out.Write(b[end:])
+	return out.Bytes()
+}
+
+// orientationEXIF is a minimal big-endian EXIF block holding only an
+// orientation
+func orientationEXIF(orientation int) []byte {
+	b := []byte("MM\x00\x2a\x00\x00\x00\x08" + // header, IFD0 at 8
+		"\x00\x01" + // one entry
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00" + // orientation, SHORT, 1
+		"\x00\x00\x00\x00") // no next IFD
+	binary.BigEndian.PutUint16(b[18:], uint16(orientation))
+	return b
+}
+
+// eachJPEGSegment calls fn for every marker segment before the image data
+// (raw bytes including the marker) and returns the offset of the start of
+// scan marker, or len(b) for malformed data
+func eachJPEGSegment(b []byte, fn func(marker byte, seg []byte)) int {
+	i := 2
+	for i+4 <= len(b) {
+		if b[i] != 0xff {
+			return len(b)
+		}
+		marker := b[i+1]
+		if marker == 0xff { // fill byte
+			i++
+			continue
+		}
+		if marker == 0xda { // start of scan: entropy-coded data follows
+			return i
+		}
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if n < 2 || i+2+n > len(b) {
+			return len(b)
+		}
+		fn(marker, b[i:i+2+n])
+		i += 2 + n
+	}
+	return len(b)
+}
+
+// eachPNGChunk calls fn for every chunk of a PNG with its type, data and raw
+// bytes (length, type, data and CRC)
+func eachPNGChunk(b []byte, fn func(typ string, data, raw []byte)) {
+	i := len(pngSignature)
+	for i+12 <= len(b) {
+		n := int(binary.BigEndian.Uint32(b[i:]))
+		if n < 0 || i+12+n > len(b) {
+			return
+		}
+		fn(string(b[i+4:i+8]), b[i+8:i+8+n], b[i:i+12+n])
+		i += 12 + n
+	}
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	w.Write(n[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	w.WriteString(typ)
+	w.Write(data)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	w.Write(n[:])
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testEXIF builds an EXIF block as a phone writes it: make, model,
+// orientation, capture time and a GPS directory
+func testEXIF(bo binary.ByteOrder, orientation int) []byte {
+	b := make([]byte, 145)
+	if bo == binary.ByteOrder(binary.LittleEndian) {
+		copy(b, "II")
+	} else {
+		copy(b, "MM")
+	}
+	bo.PutUint16(b[2:], 42)
+	bo.PutUint32(b[4:], 8)
+	entry := func(at int, tag, typ uint16, count, val uint32) {
+		bo.PutUint16(b[at:], tag)
+		bo.PutUint16(b[at+2:], typ)
+		bo.PutUint32(b[at+4:], count)
+		bo.PutUint32(b[at+8:], val)
+	}
+	bo.PutUint16(b[8:], 5)
+	entry(10, exifTagMake, 2, 7, 110)
+	entry(22, exifTagModel, 2, 8, 117)
+	entry(34, exifTagOrientation, 3, 1, 0)
+	bo.PutUint16(b[42:], uint16(orientation))
+	entry(46, exifTagExifIFD, 4, 1, 74)
+	entry(58, exifTagGPSIFD, 4, 1, 92)
+	bo.PutUint16(b[74:], 1)
+	entry(76, exifTagDateTimeOriginal, 2, 20, 125)
+	bo.PutUint16(b[92:], 1)
+	entry(94, 0x0002, 5, 3, 0) // GPSLatitude
+	copy(b[110:], "Google\x00")
+	copy(b[117:], "Pixel 7\x00")
+	copy(b[125:], "2025:03:14 18:30:05\x00")
+	return b
+}
+
+// jpegSegment returns a JPEG marker segment
+func jpegSegment(marker byte, payload string) []byte {
+	seg := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(2+len(payload)))
+	return append(seg, payload...)
+}
+
+// sidewaysJPEG returns a JPEG of a w x h image (red left half, blue right
+// half) with metadata segments inserted after the JFIF header
+func sidewaysJPEG(t *testing.T, w, h int, segs ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, halves(w, h), &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	// SOI and the APP0 segment the encoder writes
+	head := 2 + 2 + int(binary.BigEndian.Uint16(b[4:]))
+	out := append([]byte{}, b[:head]...)
+	for _, s := range segs {
+		out = append(out, s...)
+	}
+	return append(out, b[head:]...)
+}
+
+func TestReadEXIF(t *testing.T) {
+	want := time.Date(2025, 3, 14, 18, 30, 5, 0, pragueLocation())
+	for _, bo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		b := sidewaysJPEG(t, 40, 20, jpegSegment(0xe1, "Exif\x00\x00"+string(testEXIF(bo, 6))))
+		info := readEXIF(b)
+		if info.Orientation != 6 || info.Make != "Google" || info.Model != "Pixel 7" || !info.HasGPS || !info.CapturedAt.Equal(want) {
+			t.Errorf("%v: got %+v", bo, info)
+		}
+		m := info.meta()
+		if m == nil || m.Camera != "Google Pixel 7" || m.CapturedAt == nil || !m.CapturedAt.Equal(want) {
+			t.Errorf("%v: meta %+v", bo, m)
+		}
+	}
+	if info := readEXIF(sidewaysJPEG(t, 40, 20)); info != (exifInfo{}) || info.meta() != nil {
+		t.Errorf("no EXIF: got %+v", info)
+	}
+	// Truncated blocks are ignored
+	if info := readEXIF(sidewaysJPEG(t, 40, 20, jpegSegment(0xe1, "Exif\x00\x00"+string(testEXIF(binary.BigEndian, 6)[:40])))); info.Model != "" {
+		t.Errorf("truncated EXIF: got %+v", info)
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// 3x2 image with a distinct value in every pixel
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i / 4)
+	}
+	at := func(img image.Image, x, y int) uint8 { return img.(*image.RGBA).RGBAAt(x, y).R }
+	cases := []struct {
+		orientation int
+		w, h        int
+		topLeft     uint8 // source pixel index that ends up at 0,0
+		topRight    uint8
+	}{
+		{2, 3, 2, 2, 0},
+		{3, 3, 2, 5, 3},
+		{4, 3, 2, 3, 5},
+		{5, 2, 3, 0, 3},
+		{6, 2, 3, 3, 0},
+		{7, 2, 3, 5, 2},
+		{8, 2, 3, 2, 5},
+	}
+	for _, tc := range cases {
+		out := orientImage(src, tc.orientation)
+		if b := out.Bounds(); b.Dx() != tc.w || b.Dy() != tc.h {
+			t.Errorf("orientation %d: size %v", tc.orientation, b)
+			continue
+		}
+		if at(out, 0, 0) != tc.topLeft || at(out, tc.w-1, 0) != tc.topRight {
+			t.Errorf("orientation %d: corners %d, %d", tc.orientation, at(out, 0, 0), at(out, tc.w-1, 0))
+		}
+	}
+	if orientImage(src, 1) != image.Image(src) {
+		t.Error("orientation 1 should keep the image")
+	}
+}
+
+func TestStripImageMetadata(t *testing.T) {
+	exif := jpegSegment(0xe1, "Exif\x00\x00"+string(testEXIF(binary.LittleEndian, 6)))
+	xmp := jpegSegment(0xe1, "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>Pixel 7</x:xmpmeta>")
+	comment := jpegSegment(0xfe, "Google")
+	b := sidewaysJPEG(t, 40, 20, exif, xmp, comment)
+
+	out := stripImageMetadata(b, readEXIF(b).Orientation)
+	for _, s := range []string{"Google", "Pixel", "2025:", "xmpmeta"} {
+		if bytes.Contains(out, []byte(s)) {
+			t.Errorf("stripped JPEG still contains %q", s)
+		}
+	}
+	if info := readEXIF(out); info != (exifInfo{Orientation: 6}) {
+		t.Errorf("stripped JPEG EXIF: %+v", info)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+	if err != nil || cfg.Width != 40 || cfg.Height != 20 {
+		t.Errorf("stripped JPEG does not decode: %+v, %v", cfg, err)
+	}
+
+	// PNG: text and EXIF chunks go, the orientation stays
+	var pb bytes.Buffer
+	var raw bytes.Buffer
+	png.Encode(&raw, halves(8, 4))
+	chunks := raw.Bytes()
+	pb.Write(chunks[:8+25]) // signature and IHDR
+	writePNGChunk(&pb, "tEXt", []byte("Author\x00Google"))
+	writePNGChunk(&pb, "eXIf", testEXIF(binary.BigEndian, 8))
+	pb.Write(chunks[8+25:])
+	if info := readEXIF(pb.Bytes()); info.Orientation != 8 || info.Model != "Pixel 7" {
+		t.Fatalf("PNG EXIF: %+v", info)
+	}
+	out = stripImageMetadata(pb.Bytes(), 8)
+	if bytes.Contains(out, []byte("Google")) || bytes.Contains(out, []byte("Pixel")) {
+		t.Error("stripped PNG still contains metadata")
+	}
+	if info := readEXIF(out); info != (exifInfo{Orientation: 8}) {
+		t.Errorf("stripped PNG EXIF: %+v", info)
+	}
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("stripped PNG does not decode: %v", err)
+	}
+}
+
+func TestUploadUprightWithoutMetadata(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("IMAGE_ORIGINALS_PATH", filepath.Join(dir, "originals"))
+	imgPath := filepath.Join(dir, "img", "blog", "0009.png")
+
+	// Stored sideways: 1200x600 on disk, 600x1200 upright
+	b := sidewaysJPEG(t, 1200, 600,
+		jpegSegment(0xe1, "Exif\x00\x00"+string(testEXIF(binary.BigEndian, 6))),
+		jpegSegment(0xfe, "Google"))
+	p := postRecord{ID: "0009"}
+	if err := saveBlogImage(bytes.NewReader(b), &p, imgPath, defaultImageFit); err != nil {
+		t.Fatal(err)
+	}
+	if p.ImageMeta == nil || p.ImageMeta.Camera != "Google Pixel 7" || p.ImageMeta.CapturedAt == nil {
+		t.Errorf("media record: %+v", p.ImageMeta)
+	}
+
+	f, err := os.Open(imgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A tall image leaves bars left and right, none at the top
+	black := color.RGBA{0, 0, 0, 255}
+	if c := color.RGBAModel.Convert(img.At(100, blogImgH/2)); c != black {
+		t.Errorf("expected a bar on the left, got %v", c)
+	}
+	if c := color.RGBAModel.Convert(img.At(blogImgW/2, 5)); c == black {
+		t.Error("upright image should reach the top")
+	}
+
+	// Nothing stored keeps the camera data; the kept original only its
+	// orientation
+	for _, d := range []string{filepath.Dir(imgPath), filepath.Join(dir, "originals")} {
+		entries, _ := os.ReadDir(d)
+		if len(entries) == 0 {
+			t.Fatalf("nothing written to %s", d)
+		}
+		for _, e := range entries {
+			data, _ := os.ReadFile(filepath.Join(d, e.Name()))
+			if bytes.Contains(data, []byte("Google")) || bytes.Contains(data, []byte("Pixel")) || bytes.Contains(data, []byte("2025:03")) {
+				t.Errorf("%s keeps metadata", e.Name())
+			}
+		}
+	}
+	fit := p.imageFit()
+	kept, _ := os.ReadFile(originalImagePath("0009", fit.Original))
+	if info := readEXIF(kept); info != (exifInfo{Orientation: 6}) {
+		t.Errorf("kept original EXIF: %+v", info)
+	}
+}
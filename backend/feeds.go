@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ---------------- News feeds ----------------
+// /feed.xml (RSS 2.0), /atom.xml (Atom 1.0) and /feed/{category}.xml are built
+// from the same published post list as /api/blog/latest. Responses carry an
+// ETag and Last-Modified so feed readers can poll with conditional requests.
+
+const (
+	feedTitle = "FC Bizoni UH"
+	feedItems = 20
+)
+
+// defaultSiteURL is the public address of the site, used in rendered pages
+// when SITE_URL is not set
+const defaultSiteURL = "https://www.bizoniuh.cz"
+
+// siteBaseURL returns the absolute base URL of the public site, used in
+// rendered pages, feeds and the sitemap
+func siteBaseURL() string {
+	if u := os.Getenv("SITE_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return defaultSiteURL
+}
+
+// feedEntry is a post prepared for both feed formats
+type feedEntry struct {
+	BlogItem
+	URL       string
+	ImageURL  string
+	ImageSize int64
+}
+
+// feedSource collects the newest published posts of a category (empty = all)
+// and the time the newest of them changed
+func feedSource(category string) ([]feedEntry, time.Time, error) {
+	st, err := blogStoreFor(staticPath())
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	items := st.query(postQuery{Category: category})
+	if len(items) > feedItems {
+		items = items[:feedItems]
+	}
+	base := siteBaseURL()
+	var modified time.Time
+	entries := make([]feedEntry, 0, len(items))
+	for _, it := range items {
+		e := feedEntry{BlogItem: it, URL: base + it.Link}
+		if it.Image != "" {
+			e.ImageURL = base + it.Image
+			if info, err := os.Stat(filepath.Join(staticPath(), filepath.FromSlash(strings.TrimPrefix(it.Image, "/")))); err == nil {
+				e.ImageSize = info.Size()
+			}
+		}
+		if it.MTime.After(modified) {
+			modified = it.MTime
+		}
+		entries = append(entries, e)
+	}
+	return entries, modified, nil
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Self          rssLink   `xml:"atom:link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	Description string        `xml:"description,omitempty"`
+	PubDate     string        `xml:"pubDate"`
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// renderRSS builds an RSS 2.0 document
+func renderRSS(title, link, self string, entries []feedEntry, modified time.Time) ([]byte, error) {
+	ch := rssChannel{
+		Title:       title,
+		Link:        link,
+		Self:        rssLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+		Description: "Novinky z klubu " + feedTitle,
+		Language:    "cs",
+	}
+	if !modified.IsZero() {
+		ch.LastBuildDate = modified.UTC().Format(time.RFC1123Z)
+	}
+	for _, e := range entries {
+		it := rssItem{
+			Title:       e.Title,
+			Link:        e.URL,
+			GUID:        rssGUID{Value: e.URL, IsPermaLink: true},
+			Description: e.Description,
+			PubDate:     e.PublishedAt.UTC().Format(time.RFC1123Z),
+			Categories:  e.Categories,
+		}
+		if e.ImageURL != "" {
+			it.Enclosure = &rssEnclosure{URL: e.ImageURL, Length: e.ImageSize, Type: "image/png"}
+		}
+		ch.Items = append(ch.Items, it)
+	}
+	return marshalXML(rssFeed{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: ch})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// renderAtom builds an Atom 1.0 document
+func renderAtom(title, link, self string, entries []feedEntry, modified time.Time) ([]byte, error) {
+	f := atomFeed{
+		Title:   title,
+		ID:      self,
+		Links:   []atomLink{{Href: link}, {Href: self, Rel: "self", Type: "application/atom+xml"}},
+		Updated: modified.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: feedTitle},
+	}
+	for _, e := range entries {
+		ae := atomEntry{
+			Title:     e.Title,
+			ID:        e.URL,
+			Links:     []atomLink{{Href: e.URL, Rel: "alternate", Type: "text/html"}},
+			Published: e.PublishedAt.UTC().Format(time.RFC3339),
+			Updated:   e.MTime.UTC().Format(time.RFC3339),
+			Summary:   e.Description,
+		}
+		if e.ImageURL != "" {
+			ae.Links = append(ae.Links, atomLink{Href: e.ImageURL, Rel: "enclosure", Type: "image/png", Length: e.ImageSize})
+		}
+		for _, c := range e.Categories {
+			ae.Categories = append(ae.Categories, atomCategory{Term: c})
+		}
+		f.Entries = append(f.Entries, ae)
+	}
+	return marshalXML(f)
+}
+
+// marshalXML encodes v as an indented XML document
+func marshalXML(v any) ([]byte, error) {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// serveGenerated answers conditional requests for a generated document
+func serveGenerated(w http.ResponseWriter, r *http.Request, contentType string, body []byte, modified time.Time) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
+// feedHandler serves the RSS feed (atom = false) or Atom feed of all posts.
+// Under /feed/ it serves the RSS feed of the category named by the file name.
+func feedHandler(atom bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		base := siteBaseURL()
+		title, link, category := feedTitle, base+"/blog/", ""
+		if strings.HasPrefix(r.URL.Path, "/feed/") {
+			category = strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/feed/"), ".xml")
+			name := ""
+			st, err := blogStoreFor(staticPath())
+			if err == nil {
+				for _, c := range st.categories() {
+					if c.Slug == category || foldCzech(c.Name) == foldCzech(category) {
+						name = c.Name
+						break
+					}
+				}
+			}
+			if name == "" || !strings.HasSuffix(r.URL.Path, ".xml") {
+				http.NotFound(w, r)
+				return
+			}
+			title += " – " + name
+			link = base + "/blog.html?" + url.Values{"category": {name}}.Encode()
+		}
+		entries, modified, err := feedSource(category)
+		if err != nil {
+			log.Printf("feed %s: %v", r.URL.Path, err)
+			http.Error(w, "feed unavailable", http.StatusInternalServerError)
+			return
+		}
+		self := base + r.URL.Path
+		var body []byte
+		if atom {
+			body, err = renderAtom(title, link, self, entries, modified)
+		} else {
+			body, err = renderRSS(title, link, self, entries, modified)
+		}
+		if err != nil {
+			log.Printf("feed %s: %v", r.URL.Path, err)
+			http.Error(w, "feed unavailable", http.StatusInternalServerError)
+			return
+		}
+		contentType := "application/rss+xml; charset=utf-8"
+		if atom {
+			contentType = "application/atom+xml; charset=utf-8"
+		}
+		serveGenerated(w, r, contentType, body, modified)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFeeds verifies the RSS, Atom and category feeds and conditional requests.
+func TestFeeds(t *testing.T) {
+	site := t.TempDir()
+	blogDir := filepath.Join(site, "blog")
+	os.MkdirAll(filepath.Join(site, "img", "blog"), 0755)
+	os.MkdirAll(blogDir, 0755)
+	os.WriteFile(filepath.Join(blogDir, "0001.html"), []byte(`<html><head><meta name="slug" content="vyhra"><meta name="category" content="Zápasy"><meta name="description" content="Výhra 3:2"></head><body><h1 class="lte-header">Výhra</h1></body></html>`), 0644)
+	os.WriteFile(filepath.Join(site, "img", "blog", "0001.png"), []byte("png"), 0644)
+	t.Setenv("STATIC_PATH", site)
+	t.Setenv("REMOTE_BLOG_DIR", "")
+	t.Setenv("SITE_URL", "https://www.bizoniuh.cz/")
+
+	get := func(h http.HandlerFunc, path string, hdr map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range hdr {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	rss := get(feedHandler(false), "/feed.xml", nil)
+	body := rss.Body.String()
+	for _, want := range []string{
+		"<link>https://www.bizoniuh.cz/blog/vyhra</link>",
+		"<description>Výhra 3:2</description>",
+		`<enclosure url="https://www.bizoniuh.cz/img/blog/0001.png" length="3" type="image/png">`,
+		"<category>Zápasy</category>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("rss missing %q:\n%s", want, body)
+		}
+	}
+	etag := rss.Header().Get("ETag")
+	if etag == "" || rss.Header().Get("Last-Modified") == "" {
+		t.Fatal("missing validators")
+	}
+	if rec := get(feedHandler(false), "/feed.xml", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified {
+		t.Errorf("conditional request: got %d", rec.Code)
+	}
+
+	atom := get(feedHandler(true), "/atom.xml", nil).Body.String()
+	if !strings.Contains(atom, `<entry>`) || !strings.Contains(atom, `href="https://www.bizoniuh.cz/blog/vyhra"`) {
+		t.Errorf("unexpected atom feed:\n%s", atom)
+	}
+
+	if rec := get(feedHandler(false), "/feed/zapasy.xml", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "FC Bizoni UH – Zápasy") {
+		t.Errorf("category feed: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := get(feedHandler(false), "/feed/neznama.xml", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown category: got %d", rec.Code)
+	}
+
+	// links come from SITE_URL, never from the request
+	req := httptest.NewRequest(http.MethodGet, "/feed.xml", nil)
+	req.Host = "evil.example"
+	req.Header.Set("X-Forwarded-Proto", "http")
+	rec := httptest.NewRecorder()
+	feedHandler(false)(rec, req)
+	forged := rec.Body.String()
+	if strings.Contains(forged, "evil.example") || !strings.Contains(forged, "<link>https://www.bizoniuh.cz/blog/vyhra</link>") {
+		t.Errorf("feed follows the request host:\n%s", forged)
+	}
+}
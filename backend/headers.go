@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ---------------- Security headers ----------------
+// Every response carries a Content-Security-Policy, X-Content-Type-Options,
+// Referrer-Policy and frame-ancestors (plus X-Frame-Options for older
+// browsers). The pages still use inline scripts and the theme's CDNs, so the
+// policy mainly limits where scripts, frames and plugins may come from.
+//
+// ANALYTICS_ORIGIN names the analytics script host the pages include (default
+// https://rybbit.tdvorak.dev, "none" to leave it out), CONTENT_SECURITY_POLICY
+// replaces the whole policy, and CSP_REPORT_ONLY=1 sends it as
+// Content-Security-Policy-Report-Only to try a change without breaking pages.
+
+const defaultAnalyticsOrigin = "https://rybbit.tdvorak.dev"
+
+type securityPolicy struct {
+	csp        string
+	reportOnly bool
+}
+
+// loadSecurityPolicy builds the policy from the environment
+func loadSecurityPolicy() securityPolicy {
+	p := securityPolicy{
+		csp:        os.Getenv("CONTENT_SECURITY_POLICY"),
+		reportOnly: os.Getenv("CSP_REPORT_ONLY") == "1",
+	}
+	if p.csp != "" {
+		return p
+	}
+	analytics := os.Getenv("ANALYTICS_ORIGIN")
+	if analytics == "" {
+		analytics = defaultAnalyticsOrigin
+	}
+	if analytics == "none" {
+		analytics = ""
+	}
+	directives := []string{
+		"default-src 'self'",
+		"script-src 'self' 'unsafe-inline' https://unpkg.com https://cdn.jsdelivr.net https://cdnjs.cloudflare.com " +
+			"https://connect.facebook.net https://www.instagram.com https://www.googletagmanager.com " + analytics,
+		"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com https://cdn.jsdelivr.net https://unpkg.com",
+		"font-src 'self' data: https://fonts.gstatic.com",
+		// Posts and the team pages show images from other sites; the editor
+		// loads images from any URL
+		"img-src 'self' data: blob: https:",
+		"connect-src 'self' https:",
+		"frame-src https://www.youtube.com https://www.youtube-nocookie.com https://www.google.com " +
+			"https://www.facebook.com https://www.instagram.com",
+		"object-src 'none'",
+		// Post previews set <base> to the public site
+		"base-uri 'self' " + siteBaseURL(),
+		"form-action 'self'",
+		"frame-ancestors 'self'",
+	}
+	for i, d := range directives {
+		directives[i] = strings.TrimSpace(d)
+	}
+	p.csp = strings.Join(directives, "; ")
+	return p
+}
+
+// wrap sets the security headers on every response
+func (p securityPolicy) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if p.reportOnly {
+			h.Set("Content-Security-Policy-Report-Only", p.csp)
+		} else {
+			h.Set("Content-Security-Policy", p.csp)
+		}
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		h.Set("X-Frame-Options", "SAMEORIGIN")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ---------------- Hero image fit modes ----------------
+// An upload is brought to 1600x969 in one of these modes, chosen per post:
+//
+//	letterbox  whole image on a black or white background (default)
+//	blur       whole image on a blurred, darkened copy of itself
+//	cover      fills the frame, cropped around the focal point focus_x/focus_y
+//	           (0..1 from the left and top; default the center)
+//	smart      fills the frame, cropped where the image has the most detail
+//
+// The mode and focal point are stored in the post record. Every upload is
+// kept in IMAGE_ORIGINALS_PATH (default: "originals" next to club.json, not
+// served) as <id>-<content hash>.orig, stripped of its metadata except the
+// orientation (see exif.go), and the record names the one its image
+// was cut from, so the image can be cut again later without a new upload,
+// also after a revision with an older upload was restored.
+
+// Fit modes
+const (
+	fitLetterbox = "letterbox"
+	fitBlur      = "blur"
+	fitCover     = "cover"
+	fitSmart     = "smart"
+)
+
+// imageFit is how a post's hero image was cut from its upload
+type imageFit struct {
+	Mode   string  `json:"mode"`
+	FocusX float64 `json:"focus_x"`
+	FocusY float64 `json:"focus_y"`
+	// Original is the content hash of the kept upload; empty if none was kept
+	Original string `json:"original,omitempty"`
+}
+
+// defaultImageFit is the fit of posts uploaded without a choice
+var defaultImageFit = imageFit{Mode: fitLetterbox, FocusX: 0.5, FocusY: 0.5}
+
+// String describes the fit for the audit log
+func (f imageFit) String() string {
+	if f.Mode == fitCover {
+		return fmt.Sprintf("fit %s at %.2f,%.2f", f.Mode, f.FocusX, f.FocusY)
+	}
+	return "fit " + f.Mode
+}
+
+func validImageFit(mode string) bool {
+	switch mode {
+	case fitLetterbox, fitBlur, fitCover, fitSmart:
+		return true
+	}
+	return false
+}
+
+// parseImageFit reads image_fit, focus_x and focus_y from a form; set is
+// false when the form has none of them
+func parseImageFit(r *http.Request, current imageFit) (fit imageFit, set bool, err error) {
+	fit = current
+	if v := strings.TrimSpace(r.FormValue("image_fit")); v != "" {
+		if !validImageFit(v) {
+			return fit, false, fmt.Errorf("invalid image_fit %q", v)
+		}
+		fit.Mode, set = v, true
+	}
+	for _, f := range []struct {
+		key string
+		dst *float64
+	}{{"focus_x", &fit.FocusX}, {"focus_y", &fit.FocusY}} {
+		v := strings.TrimSpace(r.FormValue(f.key))
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 || n > 1 || math.IsNaN(n) {
+			return fit, false, fmt.Errorf("invalid %s %q (0..1)", f.key, v)
+		}
+		*f.dst, set = n, true
+	}
+	return fit, set, nil
+}
+
+// imageFit returns the stored fit of p, or the default
+func (p *postRecord) imageFit() imageFit {
+	if p.ImageFit != nil {
+		return *p.ImageFit
+	}
+	return defaultImageFit
+}
+
+// composeBlogImage brings img to the hero frame with the given fit
+func composeBlogImage(img image.Image, fit imageFit) *image.RGBA {
+	switch fit.Mode {
+	case fitBlur:
+		canvas := blurredBackground(img)
+		fitOnto(canvas, img)
+		return canvas
+	case fitCover:
+		return coverFrame(img, fit.FocusX, fit.FocusY)
+	case fitSmart:
+		fx, fy := detailFocus(img, blogImgW, blogImgH)
+		return coverFrame(img, fx, fy)
+	}
+	return letterbox(img)
+}
+
+// fitOnto draws img scaled to fit, centered on canvas
+func fitOnto(canvas *image.RGBA, img image.Image) {
+	srcB := img.Bounds()
+	cw, ch := canvas.Rect.Dx(), canvas.Rect.Dy()
+	dw, dh := fitWithin(srcB.Dx(), srcB.Dy(), cw, ch, imageMaxUpscale())
+	var scaled image.Image = img
+	if dw != srcB.Dx() || dh != srcB.Dy() {
+		scaled = resample(img, dw, dh, imageResampleFilter())
+	}
+	offX := (cw - dw) / 2
+	offY := (ch - dh) / 2
+	draw.Draw(canvas, image.Rect(offX, offY, offX+dw, offY+dh), scaled, scaled.Bounds().Min, draw.Over)
+}
+
+// coverFrame fills the hero frame with a cover crop; a window that would have
+// to be enlarged more than IMAGE_MAX_UPSCALE is enlarged only that much and
+// letterboxed instead
+func coverFrame(img image.Image, fx, fy float64) *image.RGBA {
+	b := img.Bounds()
+	cw, ch := coverWindow(b.Dx(), b.Dy(), blogImgW, blogImgH)
+	if float64(blogImgW) > float64(cw)*imageMaxUpscale() {
+		return letterbox(coverCrop(img, fx, fy, cw, ch))
+	}
+	return coverCrop(img, fx, fy, blogImgW, blogImgH)
+}
+
+// coverWindow returns the size of the largest window of a sw x sh image that
+// has the aspect ratio of w x h
+func coverWindow(sw, sh, w, h int) (int, int) {
+	cw, ch := sw, sw*h/w
+	if ch > sh {
+		cw, ch = sh*w/h, sh
+	}
+	return max(cw, 1), max(ch, 1)
+}
+
+// coverCrop fills w x h with the largest window of img that has the frame's
+// aspect ratio, centered on the focal point as far as the edges allow
+func coverCrop(img image.Image, fx, fy float64, w, h int) *image.RGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	cw, ch := coverWindow(sw, sh, w, h)
+	x0 := min(max(int(fx*float64(sw))-cw/2, 0), sw-cw)
+	y0 := min(max(int(fy*float64(sh))-ch/2, 0), sh-ch)
+	window := toRGBA(img).SubImage(image.Rect(x0, y0, x0+cw, y0+ch))
+	return resample(window, w, h, imageResampleFilter())
+}
+
+// blurredBackground returns a blurred, darkened cover crop of img; shrinking
+// to a few dozen pixels and enlarging again is a cheap wide blur
+func blurredBackground(img image.Image) *image.RGBA {
+	bilinear := resampleFilters[0]
+	small := resample(coverCrop(img, 0.5, 0.5, blogImgW/4, blogImgH/4), blogImgW/40, blogImgH/40, bilinear)
+	small = resample(small, blogImgW/8, blogImgH/8, bilinear)
+	bg := resample(small, blogImgW, blogImgH, bilinear)
+	for i := 0; i < len(bg.Pix); i += 4 {
+		bg.Pix[i] = uint8(uint16(bg.Pix[i]) * 3 / 4)
+		bg.Pix[i+1] = uint8(uint16(bg.Pix[i+1]) * 3 / 4)
+		bg.Pix[i+2] = uint8(uint16(bg.Pix[i+2]) * 3 / 4)
+		bg.Pix[i+3] = 255
+	}
+	return bg
+}
+
+// detailFocus returns the center of the crop window of the frame's aspect
+// ratio with the highest luminance entropy, measured on a small copy
+func detailFocus(img image.Image, w, h int) (float64, float64) {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw == 0 || sh == 0 {
+		return 0.5, 0.5
+	}
+	// Work on at most 160 pixels along the longer side
+	scale := math.Min(1, 160/float64(max(sw, sh)))
+	tw, th := max(int(float64(sw)*scale), 1), max(int(float64(sh)*scale), 1)
+	thumb := resample(img, tw, th, resampleFilters[0])
+	gray := make([]uint8, tw*th)
+	for i := range gray {
+		p := thumb.Pix[i*4:]
+		gray[i] = uint8((299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])) / 1000)
+	}
+	cw, ch := tw, tw*h/w
+	if ch > th {
+		cw, ch = th*w/h, th
+	}
+	cw, ch = max(cw, 1), max(ch, 1)
+	bestX, bestY, best := 0, 0, -1.0
+	for y := 0; y <= th-ch; y += 2 {
+		for x := 0; x <= tw-cw; x += 2 {
+			if e := windowEntropy(gray, tw, x, y, cw, ch); e > best {
+				bestX, bestY, best = x, y, e
+			}
+		}
+	}
+	return (float64(bestX) + float64(cw)/2) / float64(tw), (float64(bestY) + float64(ch)/2) / float64(th)
+}
+
+// windowEntropy is the Shannon entropy of the luminance histogram of a window
+func windowEntropy(gray []uint8, stride, x0, y0, w, h int) float64 {
+	var hist [64]int
+	for y := y0; y < y0+h; y++ {
+		for _, v := range gray[y*stride+x0 : y*stride+x0+w] {
+			hist[v>>2]++
+		}
+	}
+	n := float64(w * h)
+	e := 0.0
+	for _, c := range hist {
+		if c > 0 {
+			p := float64(c) / n
+			e -= p * math.Log2(p)
+		}
+	}
+	return e
+}
+
+// letterbox places the whole image on a black or white background
+func letterbox(img image.Image) *image.RGBA {
+	// Choose background based on average luminance
+	var bg color.Color = color.Black
+	if avgLuma(img) > 160 { // bright image -> white bg; tweak threshold as needed
+		bg = color.White
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, blogImgW, blogImgH))
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	fitOnto(canvas, img)
+	return canvas
+}
+
+// imageOriginalsDir holds the uploaded originals of hero images
+func imageOriginalsDir() string {
+	if p := os.Getenv("IMAGE_ORIGINALS_PATH"); p != "" {
+		return p
+	}
+	return filepath.Join(filepath.Dir(dataPath()), "originals")
+}
+
+func originalImagePath(id, hash string) string {
+	return filepath.Join(imageOriginalsDir(), id+"-"+hash+".orig")
+}
+
+// removeOriginalImages deletes every kept upload of post id
+func removeOriginalImages(id string) {
+	paths, _ := filepath.Glob(filepath.Join(imageOriginalsDir(), id+"-*.orig"))
+	for _, p := range paths {
+		_ = os.Remove(p)
+	}
+}
+
+// saveBlogImage normalizes an upload for post p to imgPath with fit, keeps
+// the original without its metadata and records the image in p
+func saveBlogImage(r io.Reader, p *postRecord, imgPath string, fit imageFit) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read upload: %w", err)
+	}
+	sources, err := normalizeBlogImage(bytes.NewReader(b), imgPath, fit)
+	if err != nil {
+		return err
+	}
+	exif := readEXIF(b)
+	if exif.HasGPS {
+		log.Printf("blog image %s: GPS position removed from the upload", p.ID)
+	}
+	kept := stripImageMetadata(b, exif.Orientation)
+	sum := sha256.Sum256(kept)
+	hash := hex.EncodeToString(sum[:8])
+	path := originalImagePath(p.ID, hash)
+	fit.Original = ""
+	if err := writeFileAtomic(path, kept); err != nil {
+		log.Printf("warn: keep original image of %s: %v", p.ID, err)
+	} else {
+		_ = os.Chmod(path, 0600)
+		fit.Original = hash
+	}
+	p.ImageSources = sources
+	p.ImageFit = &fit
+	p.ImageMeta = exif.meta()
+	return nil
+}
+
+// regenerateBlogImage cuts the kept original of post id again with fit; the
+// error wraps os.ErrNotExist when no original was kept
+func regenerateBlogImage(id, imgPath string, fit imageFit) ([]imageSource, error) {
+	if fit.Original == "" {
+		return nil, fmt.Errorf("original image of %s: %w", id, os.ErrNotExist)
+	}
+	f, err := os.Open(originalImagePath(id, fit.Original))
+	if err != nil {
+		return nil, fmt.Errorf("original image of %s: %w", id, err)
+	}
+	defer f.Close()
+	return normalizeBlogImage(f, imgPath, fit)
+}
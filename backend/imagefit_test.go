@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseImageFit(t *testing.T) {
+	cases := []struct {
+		query string
+		want  imageFit
+		set   bool
+		err   bool
+	}{
+		{"", defaultImageFit, false, false},
+		{"image_fit=cover&focus_x=0.25&focus_y=1", imageFit{Mode: fitCover, FocusX: 0.25, FocusY: 1}, true, false},
+		{"image_fit=smart", imageFit{Mode: fitSmart, FocusX: 0.5, FocusY: 0.5}, true, false},
+		{"image_fit=stretch", imageFit{}, false, true},
+		{"image_fit=cover&focus_x=1.5", imageFit{}, false, true},
+		{"focus_y=NaN", imageFit{}, false, true},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tc.query))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		fit, set, err := parseImageFit(r, defaultImageFit)
+		if (err != nil) != tc.err {
+			t.Errorf("%q: err = %v", tc.query, err)
+			continue
+		}
+		if !tc.err && (fit != tc.want || set != tc.set) {
+			t.Errorf("%q: got %+v set=%v, want %+v set=%v", tc.query, fit, set, tc.want, tc.set)
+		}
+	}
+	// The stored fit is kept for fields that are not sent
+	cur := imageFit{Mode: fitCover, FocusX: 0.2, FocusY: 0.8, Original: "ab"}
+	r := httptest.NewRequest("POST", "/", strings.NewReader(url.Values{"focus_x": {"0.6"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if fit, _, _ := parseImageFit(r, cur); fit != (imageFit{Mode: fitCover, FocusX: 0.6, FocusY: 0.8, Original: "ab"}) {
+		t.Errorf("current fit not kept: %+v", fit)
+	}
+}
+
+// halves returns a w x h image, red on the left half and blue on the right
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{220, 0, 0, 255}
+			if x >= w/2 {
+				c = color.RGBA{0, 0, 220, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestComposeBlogImage(t *testing.T) {
+	src := halves(3200, 900)
+
+	// Cover fills the frame and follows the focal point
+	left := composeBlogImage(src, imageFit{Mode: fitCover, FocusX: 0, FocusY: 0.5})
+	right := composeBlogImage(src, imageFit{Mode: fitCover, FocusX: 1, FocusY: 0.5})
+	if b := left.Bounds(); b.Dx() != blogImgW || b.Dy() != blogImgH {
+		t.Fatalf("cover size %v", b)
+	}
+	if c := left.RGBAAt(blogImgW-1, blogImgH/2); c.R < 200 {
+		t.Errorf("focus left: right edge is %v, want red", c)
+	}
+	if c := right.RGBAAt(0, blogImgH/2); c.B < 200 {
+		t.Errorf("focus right: left edge is %v, want blue", c)
+	}
+
+	// Letterbox leaves bars, blur fills them with the darkened image
+	box := composeBlogImage(src, defaultImageFit)
+	if c := box.RGBAAt(blogImgW/2, 0); c != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("letterbox bar is %v, want black", c)
+	}
+	blur := composeBlogImage(src, imageFit{Mode: fitBlur})
+	if c := blur.RGBAAt(10, 0); c.R < 100 || c.R > 180 || c.A != 255 {
+		t.Errorf("blur background is %v, want darkened red", c)
+	}
+	if c := blur.RGBAAt(10, blogImgH/2); c.R < 200 {
+		t.Errorf("blur foreground is %v, want the image", c)
+	}
+
+	// A small source is enlarged at most IMAGE_MAX_UPSCALE and letterboxed
+	t.Setenv("IMAGE_MAX_UPSCALE", "2")
+	small := composeBlogImage(halves(400, 300), imageFit{Mode: fitCover, FocusX: 0.5, FocusY: 0.5})
+	if b := small.Bounds(); b.Dx() != blogImgW || b.Dy() != blogImgH {
+		t.Fatalf("small cover size %v", b)
+	}
+	// The 400x242 window becomes 800x484, centered
+	if c := small.RGBAAt(blogImgW/2-350, blogImgH/2); c.R < 200 {
+		t.Errorf("small cover image is %v, want red", c)
+	}
+	for _, p := range []image.Point{{blogImgW/2 - 450, blogImgH / 2}, {blogImgW / 2, blogImgH/2 - 300}} {
+		if c := small.RGBAAt(p.X, p.Y); c != (color.RGBA{0, 0, 0, 255}) {
+			t.Errorf("small cover at %v is %v, want the black bar", p, c)
+		}
+	}
+	if smart := composeBlogImage(halves(400, 300), imageFit{Mode: fitSmart}); smart.RGBAAt(0, 0) != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("small smart crop is not letterboxed")
+	}
+}
+
+func TestDetailFocus(t *testing.T) {
+	// Flat gray with a detailed area on the right
+	img := image.NewRGBA(image.Rect(0, 0, 3000, 900))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []uint8{128, 128, 128, 255})
+	}
+	rng := rand.New(rand.NewSource(1))
+	for y := 200; y < 700; y++ {
+		for x := 2300; x < 2900; x++ {
+			v := uint8(rng.Intn(256))
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	fx, fy := detailFocus(img, blogImgW, blogImgH)
+	if fx < 0.6 || fy != 0.5 {
+		t.Errorf("focus %.2f,%.2f, want right of center", fx, fy)
+	}
+	out := composeBlogImage(img, imageFit{Mode: fitSmart})
+	if c := out.RGBAAt(0, 0); c != (color.RGBA{128, 128, 128, 255}) {
+		t.Errorf("smart crop should keep gray on the left, got %v", c)
+	}
+}
+
+func TestKeptOriginal(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("IMAGE_ORIGINALS_PATH", filepath.Join(dir, "originals"))
+	imgPath := filepath.Join(dir, "img", "blog", "0007.png")
+
+	p := postRecord{ID: "0007"}
+	if err := saveBlogImage(encodePNG(t, halves(3200, 900)), &p, imgPath, imageFit{Mode: fitCover, FocusX: 0, FocusY: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	fit := p.imageFit()
+	if fit.Original == "" || len(p.ImageSources) == 0 {
+		t.Fatal("original not recorded")
+	}
+	info, err := os.Stat(originalImagePath("0007", fit.Original))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("original not kept privately: %v, %v", info, err)
+	}
+
+	// Cut again around the other side
+	fit.FocusX = 1
+	if _, err := regenerateBlogImage("0007", imgPath, fit); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(imgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, b, _ := img.At(0, blogImgH/2).RGBA(); b>>8 < 200 {
+		t.Error("image not regenerated with the new focal point")
+	}
+
+	removeOriginalImages("0007")
+	if _, err := regenerateBlogImage("0007", imgPath, fit); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("after removal: %v", err)
+	}
+	if _, err := regenerateBlogImage("0007", imgPath, defaultImageFit); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("without original: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// ---------------- Responsive image variants ----------------
+// Next to the 1600x969 PNG every hero image is stored in the widths of
+// imageVariantWidths as JPEG (<id>-<width>.jpg) and, where it is smaller,
+// WebP (<id>-<width>.webp). The only WebP encoder available in pure Go is
+// lossless, which beats JPEG on flat graphics and posters but not on photos,
+// so a WebP file is kept only when it is smaller than the JPEG of the same
+// width. JPEG uploads are photos and are not tried at all, and once WebP
+// loses at one width the larger widths are not tried either. Match photos,
+// most hero images, therefore get JPEG variants only; WebP is effectively
+// written for graphics alone. There is no Go AVIF encoder, so no AVIF files
+// are written.
+//
+// The variants of a post are listed in postRecord.ImageSources and exposed
+// as BlogItem.Sources for srcset; `server image-variants` regenerates them
+// for every post from its kept upload (see imagefit.go) or else the stored
+// PNG. A running server picks up variants written by the command when it
+// starts again.
+
+// imageVariantWidths are the generated widths, smallest first
+var imageVariantWidths = []int{400, 800, 1600}
+
+const imageVariantJPEGQuality = 82
+
+// imageSource is one stored variant of a post image
+type imageSource struct {
+	URL    string `json:"url"`
+	Type   string `json:"type"` // image/jpeg or image/webp
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// writeImageVariants writes the variants of the normalized image img stored
+// at pngPath (<dir>/<id>.png) and returns them, smallest first; webp is
+// false for photos, which lossless WebP never wins
+func writeImageVariants(img image.Image, pngPath string, webp bool) ([]imageSource, error) {
+	base := strings.TrimSuffix(filepath.Base(pngPath), ".png")
+	return writeSizedVariants(img, filepath.Dir(pngPath), base, "/img/blog/", webp)
+}
+
+// writeSizedVariants writes img as <dir>/<base>-<width>.jpg (and, if webp,
+// .webp) in the widths of imageVariantWidths, served under urlDir. Widths
+// above the image's own are replaced by a single variant at its own width.
+func writeSizedVariants(img image.Image, dir, base, urlDir string, webp bool) ([]imageSource, error) {
+	b := img.Bounds()
+	var out []imageSource
+	for _, w := range imageVariantWidths {
+		if len(out) > 0 && out[len(out)-1].Width == b.Dx() {
+			break
+		}
+		w = min(w, b.Dx())
+		h := max((b.Dy()*w+b.Dx()/2)/b.Dx(), 1)
+		var scaled image.Image = img
+		if w != b.Dx() {
+			scaled = resample(img, w, h, imageResampleFilter())
+		}
+		name := base + "-" + strconv.Itoa(w)
+
+		var jb bytes.Buffer
+		if err := jpeg.Encode(&jb, scaled, &jpeg.Options{Quality: imageVariantJPEGQuality}); err != nil {
+			return nil, fmt.Errorf("encode jpeg %d: %w", w, err)
+		}
+		if err := writeFileAtomic(filepath.Join(dir, name+".jpg"), jb.Bytes()); err != nil {
+			return nil, err
+		}
+		out = append(out, imageSource{URL: urlDir + name + ".jpg", Type: "image/jpeg", Width: w, Height: h})
+
+		webpPath := filepath.Join(dir, name+".webp")
+		if !webp {
+			_ = os.Remove(webpPath)
+			continue
+		}
+		var wb bytes.Buffer
+		if err := nativewebp.Encode(&wb, scaled, nil); err != nil {
+			return nil, fmt.Errorf("encode webp %d: %w", w, err)
+		}
+		if wb.Len() >= jb.Len() {
+			// Larger widths of the same image will not do better
+			webp = false
+			_ = os.Remove(webpPath)
+			continue
+		}
+		if err := writeFileAtomic(webpPath, wb.Bytes()); err != nil {
+			return nil, err
+		}
+		out = append(out, imageSource{URL: urlDir + name + ".webp", Type: "image/webp", Width: w, Height: h})
+	}
+	return out, nil
+}
+
+// imageVariantsFromPNG regenerates the variants of a stored post image; the
+// format of the upload is not known, so WebP is tried at the smallest width
+func imageVariantsFromPNG(pngPath string) ([]imageSource, error) {
+	f, err := os.Open(pngPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", filepath.Base(pngPath), err)
+	}
+	return writeImageVariants(img, pngPath, true)
+}
+
+// removeImageVariants deletes the variants of a post image
+func removeImageVariants(pngPath string) {
+	base := strings.TrimSuffix(pngPath, ".png")
+	for _, w := range imageVariantWidths {
+		for _, ext := range []string{".jpg", ".webp"} {
+			_ = os.Remove(base + "-" + strconv.Itoa(w) + ext)
+		}
+	}
+}
+
+// storedImageVariants lists the variant files present for a post image
+func storedImageVariants(pngPath string) []imageSource {
+	base := strings.TrimSuffix(pngPath, ".png")
+	var out []imageSource
+	for _, w := range imageVariantWidths {
+		for _, v := range []struct{ ext, typ string }{{".jpg", "image/jpeg"}, {".webp", "image/webp"}} {
+			path := base + "-" + strconv.Itoa(w) + v.ext
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			h := (blogImgH*w + blogImgW/2) / blogImgW
+			out = append(out, imageSource{URL: "/img/blog/" + filepath.Base(path), Type: v.typ, Width: w, Height: h})
+		}
+	}
+	return out
+}
+
+// fillImageSourcesLocked lists the variant files of records that have none
+// recorded, e.g. written by `server image-variants`
+func (s *postStore) fillImageSourcesLocked() int {
+	imgDir := filepath.Join(filepath.Dir(s.blogDir), "img", "blog")
+	n := 0
+	for _, p := range s.byID {
+		if len(p.ImageSources) > 0 {
+			continue
+		}
+		if p.ImageSources = storedImageVariants(filepath.Join(imgDir, p.ID+".png")); len(p.ImageSources) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// regenerateImageVariants writes the variants of every post with an image,
+// re-cut from the kept upload if there is one, and stores them in the records
+func (s *postStore) regenerateImageVariants() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	imgDir := filepath.Join(filepath.Dir(s.blogDir), "img", "blog")
+	n := 0
+	for _, p := range s.byID {
+		pngPath := filepath.Join(imgDir, p.ID+".png")
+		if _, err := os.Stat(pngPath); err != nil {
+			continue
+		}
+		// Cut again from the kept upload where there is one, so changes of
+		// the fit modes or filters reach older posts too
+		sources, err := regenerateBlogImage(p.ID, pngPath, p.imageFit())
+		if errors.Is(err, os.ErrNotExist) {
+			sources, err = imageVariantsFromPNG(pngPath)
+		}
+		if err != nil {
+			return n, fmt.Errorf("post %s: %w", p.ID, err)
+		}
+		p.ImageSources = sources
+		n++
+	}
+	return n, s.saveLocked()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encodePNG returns img as PNG bytes
+func encodePNG(t *testing.T, img image.Image) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// TestDecodeImageBudget verifies an image whose header declares a huge canvas
+// is refused before it is decoded, on both the blog and the media path
+func TestDecodeImageBudget(t *testing.T) {
+	b := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1))).Bytes()
+	// Declare 40000x40000 pixels in IHDR and fix its checksum
+	binary.BigEndian.PutUint32(b[16:], 40000)
+	binary.BigEndian.PutUint32(b[20:], 40000)
+	binary.BigEndian.PutUint32(b[29:], crc32.ChecksumIEEE(b[12:29]))
+
+	dir := t.TempDir()
+	if _, err := normalizeBlogImage(bytes.NewReader(b), filepath.Join(dir, "0001.png"), defaultImageFit); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("blog image: %v", err)
+	}
+	if err := storeMediaImage(&mediaAsset{ID: "1"}, b, dir); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("media image: %v", err)
+	}
+	if _, _, err := decodeImage(encodePNG(t, halves(400, 300)).Bytes()); err != nil {
+		t.Errorf("small image refused: %v", err)
+	}
+}
+
+func TestImageVariants(t *testing.T) {
+	dir := t.TempDir()
+
+	// A flat poster: lossless WebP beats JPEG
+	flat := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 800; x++ {
+			flat.Set(x, y, color.RGBA{200, uint8(x / 100 * 30), 40, 255})
+		}
+	}
+	flatPath := filepath.Join(dir, "0001.png")
+	sources, err := normalizeBlogImage(encodePNG(t, flat), flatPath, defaultImageFit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []imageSource{
+		{URL: "/img/blog/0001-400.jpg", Type: "image/jpeg", Width: 400, Height: 242},
+		{URL: "/img/blog/0001-400.webp", Type: "image/webp", Width: 400, Height: 242},
+		{URL: "/img/blog/0001-800.jpg", Type: "image/jpeg", Width: 800, Height: 485},
+		{URL: "/img/blog/0001-800.webp", Type: "image/webp", Width: 800, Height: 485},
+		{URL: "/img/blog/0001-1600.jpg", Type: "image/jpeg", Width: 1600, Height: 969},
+		{URL: "/img/blog/0001-1600.webp", Type: "image/webp", Width: 1600, Height: 969},
+	}
+	if !reflect.DeepEqual(sources, want) {
+		t.Fatalf("unexpected variants:\n%+v", sources)
+	}
+	f, err := os.Open(filepath.Join(dir, "0001-800.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(f)
+	f.Close()
+	if err != nil || cfg.Width != 800 || cfg.Height != 485 {
+		t.Errorf("bad 800 variant: %+v, %v", cfg, err)
+	}
+	if got := storedImageVariants(flatPath); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored variants differ:\n%+v", got)
+	}
+
+	// A JPEG upload is a photo: WebP is not even tried, whatever the content
+	var jb bytes.Buffer
+	if err := jpeg.Encode(&jb, flat, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	sources, err = normalizeBlogImage(&jb, filepath.Join(dir, "0004.png"), defaultImageFit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 3 || sources[0].Type != "image/jpeg" || sources[1].Type != "image/jpeg" || sources[2].Type != "image/jpeg" {
+		t.Errorf("jpeg upload: unexpected variants %+v", sources)
+	}
+	sources, err = writeSizedVariants(flat, dir, "plakat", "/media/", false)
+	if err != nil || len(sources) != 2 || sources[1].Type != "image/jpeg" {
+		t.Errorf("webp disabled: %+v %v", sources, err)
+	}
+
+	// Noise compresses badly without loss: only JPEG is kept
+	rng := rand.New(rand.NewSource(1))
+	noise := image.NewRGBA(image.Rect(0, 0, 1600, 969))
+	rng.Read(noise.Pix)
+	for i := 3; i < len(noise.Pix); i += 4 {
+		noise.Pix[i] = 255
+	}
+	noisePath := filepath.Join(dir, "0002.png")
+	os.WriteFile(noisePath, encodePNG(t, noise).Bytes(), 0644)
+	sources, err = imageVariantsFromPNG(noisePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range sources {
+		if s.Type != "image/jpeg" {
+			t.Errorf("unexpected %s variant %s", s.Type, s.URL)
+		}
+	}
+	if len(sources) != 3 {
+		t.Errorf("expected 3 JPEG variants, got %+v", sources)
+	}
+
+	// A photo: smooth shading with sensor noise is JPEG only as well
+	photo := image.NewRGBA(image.Rect(0, 0, 1600, 969))
+	for y := 0; y < 969; y++ {
+		for x := 0; x < 1600; x++ {
+			n := uint8(rng.Intn(12))
+			photo.Set(x, y, color.RGBA{uint8(60+x/16) + n, uint8(90+y/10) + n, 120 + n, 255})
+		}
+	}
+	photoPath := filepath.Join(dir, "0003.png")
+	os.WriteFile(photoPath, encodePNG(t, photo).Bytes(), 0644)
+	sources, err = imageVariantsFromPNG(photoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range sources {
+		if s.Type != "image/jpeg" {
+			t.Errorf("photo: unexpected %s variant %s", s.Type, s.URL)
+		}
+	}
+	if len(sources) != 3 {
+		t.Errorf("photo: expected 3 JPEG variants, got %+v", sources)
+	}
+
+	removeImageVariants(flatPath)
+	if got := storedImageVariants(flatPath); len(got) != 0 {
+		t.Errorf("variants left after removal: %+v", got)
+	}
+}
+
+// TestPostStoreImageSources verifies variants written outside the server are
+// picked up when the store opens and listed in BlogItem.Sources
+func TestPostStoreImageSources(t *testing.T) {
+	root := t.TempDir()
+	blogDir := filepath.Join(root, "blog")
+	imgDir := filepath.Join(root, "img", "blog")
+	os.MkdirAll(blogDir, 0755)
+	os.MkdirAll(imgDir, 0755)
+	storePath := filepath.Join(root, "data", "posts.json")
+	var st postStore
+	if err := st.open(blogDir, storePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.put(postRecord{ID: "0001", Slug: "a", Title: "A", ContentMode: contentVisual, Body: "<p>a</p>", Image: "/img/blog/0001.png", Status: postPublished}); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, blogImgW, blogImgH))
+	os.WriteFile(filepath.Join(imgDir, "0001.png"), encodePNG(t, img).Bytes(), 0644)
+
+	var cmd postStore
+	if err := cmd.open(blogDir, storePath); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := cmd.regenerateImageVariants(); err != nil || n != 1 {
+		t.Fatalf("regenerate: %d, %v", n, err)
+	}
+
+	// The server's own records have no sources and its save drops them
+	if _, err := st.put(postRecord{ID: "0001", Slug: "a", Title: "A2", ContentMode: contentVisual, Body: "<p>a</p>", Image: "/img/blog/0001.png", Status: postPublished}); err != nil {
+		t.Fatal(err)
+	}
+	var reopened postStore
+	if err := reopened.open(blogDir, storePath); err != nil {
+		t.Fatal(err)
+	}
+	items := reopened.list(0)
+	if len(items) != 1 || len(items[0].Sources) < 3 || items[0].Sources[0].URL != "/img/blog/0001-400.jpg" {
+		t.Errorf("sources not listed: %+v", items)
+	}
+}
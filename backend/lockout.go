@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------- Login brute-force protection ----------------
+// Failed logins (login form and Basic auth) are counted per client IP and per
+// user name. After a few free attempts every further failure locks the key
+// for twice as long as the previous one, up to lockoutMax; while locked no
+// password is checked at all. Counts are forgotten after failureWindow
+// without failures, and a successful login clears the user name's count.
+
+const (
+	lockoutBase   = time.Second
+	lockoutMax    = 15 * time.Minute
+	failureWindow = time.Hour
+	// userFreeFailures and ipFreeFailures are the failures allowed before the
+	// first lockout; an IP gets more since the club house shares one address
+	userFreeFailures = 5
+	ipFreeFailures   = 10
+)
+
+type failureState struct {
+	fails  int
+	last   time.Time
+	locked time.Time // locked until
+}
+
+// failureTracker counts failed attempts per key, like rateLimiter but keyed
+type failureTracker struct {
+	mu      sync.Mutex
+	free    int
+	entries map[string]*failureState
+}
+
+var (
+	ipFailures   = failureTracker{free: ipFreeFailures}
+	userFailures = failureTracker{free: userFreeFailures}
+)
+
+// wait returns how long key stays locked
+func (t *failureTracker) wait(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e := t.entries[key]; e != nil && now.Before(e.locked) {
+		return e.locked.Sub(now)
+	}
+	return 0
+}
+
+// fail records a failed attempt and returns the resulting lockout (0 if none)
+func (t *failureTracker) fail(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.entries == nil {
+		t.entries = make(map[string]*failureState)
+	}
+	// Keep the map small when someone sprays random names
+	if len(t.entries) > 1024 {
+		for k, e := range t.entries {
+			if now.Sub(e.last) > failureWindow && !now.Before(e.locked) {
+				delete(t.entries, k)
+			}
+		}
+	}
+	e := t.entries[key]
+	if e == nil || now.Sub(e.last) > failureWindow {
+		e = &failureState{}
+		t.entries[key] = e
+	}
+	e.fails++
+	e.last = now
+	if e.fails <= t.free {
+		return 0
+	}
+	d := lockoutMax
+	if n := e.fails - t.free - 1; n < 20 {
+		d = min(lockoutBase<<n, lockoutMax)
+	}
+	e.locked = now.Add(d)
+	return d
+}
+
+// reset forgets the failures of key
+func (t *failureTracker) reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+}
+
+// loginWait returns how long logins from ip or for name are locked
+func loginWait(ip, name string, now time.Time) time.Duration {
+	return max(ipFailures.wait(ip, now), userFailures.wait(strings.ToLower(name), now))
+}
+
+// checkLogin authenticates name and password unless ip or name is locked;
+// via names the login path ("login form", "basic auth") in the log
+func checkLogin(ip, name, password, via string) (adminUser, time.Duration, bool) {
+	now := time.Now()
+	if wait := loginWait(ip, name, now); wait > 0 {
+		log.Printf("admin %s for %q from %s refused: locked for %s", via, name, ip, wait.Round(time.Second))
+		return adminUser{}, wait, false
+	}
+	u, ok := users.authenticate(name, password)
+	if ok {
+		userFailures.reset(strings.ToLower(name))
+		return u, 0, true
+	}
+	// The lockout starts once the slow password check is over
+	now = time.Now()
+	lock := max(ipFailures.fail(ip, now), userFailures.fail(strings.ToLower(name), now))
+	if lock > 0 {
+		log.Printf("admin %s failed for %q from %s; locked for %s", via, name, ip, lock)
+	} else {
+		log.Printf("admin %s failed for %q from %s", via, name, ip)
+	}
+	return adminUser{}, lock, false
+}
+
+// tooManyAttempts answers 429 with a Retry-After header
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(retrySeconds(wait)))
+	http.Error(w, fmt.Sprintf("too many failed logins, retry in %ds", retrySeconds(wait)), http.StatusTooManyRequests)
+}
+
+// retrySeconds rounds a lockout up to whole seconds
+func retrySeconds(wait time.Duration) int {
+	return int((wait + time.Second - 1) / time.Second)
+}
+
+// clientIP returns the address a request came from. X-Real-IP is only
+// believed from the proxies in TRUSTED_PROXIES: the port is published
+// directly, and Docker's NAT makes outside clients look like a private peer.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil && trustedProxy(ip) {
+		if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(real) != nil {
+			return real
+		}
+	}
+	return host
+}
+
+// trustedProxy reports whether ip is listed in TRUSTED_PROXIES
+// (comma-separated addresses or CIDR ranges; default none)
+func trustedProxy(ip net.IP) bool {
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		p = strings.TrimSpace(p)
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			if n.Contains(ip) {
+				return true
+			}
+		} else if pip := net.ParseIP(p); pip != nil && pip.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
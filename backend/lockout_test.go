@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFailureTrackerBackoff checks the free attempts, the doubling lockout,
+// its cap and the forgetting after failureWindow.
+func TestFailureTrackerBackoff(t *testing.T) {
+	tr := failureTracker{free: 2}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if d := tr.fail("k", now); d != 0 {
+			t.Fatalf("free failure %d locked for %s", i+1, d)
+		}
+	}
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if d := tr.fail("k", now); d != want {
+			t.Fatalf("lockout %s, want %s", d, want)
+		}
+		if w := tr.wait("k", now); w != want {
+			t.Fatalf("wait %s, want %s", w, want)
+		}
+	}
+	for i := 0; i < 30; i++ {
+		tr.fail("k", now)
+	}
+	if w := tr.wait("k", now); w != lockoutMax {
+		t.Fatalf("capped wait %s", w)
+	}
+	if w := tr.wait("other", now); w != 0 {
+		t.Fatalf("other key locked for %s", w)
+	}
+	later := now.Add(lockoutMax + failureWindow + time.Minute)
+	if d := tr.fail("k", later); d != 0 {
+		t.Fatalf("failures not forgotten: %s", d)
+	}
+}
+
+// TestLoginLockout locks a user name out of the login form, refuses even the
+// right password while locked and logs the client IP from the proxy header.
+func TestLoginLockout(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "127.0.0.1, 10.0.0.0/8")
+	if err := users.open(filepath.Join(t.TempDir(), "users.json")); err != nil {
+		t.Fatal(err)
+	}
+	defer users.open(filepath.Join(t.TempDir(), "none.json"))
+	if _, err := users.set("pokladnik", roleViewer, "heslo-pokladnika", true); err != nil {
+		t.Fatal(err)
+	}
+	defer userFailures.reset("pokladnik")
+	defer ipFailures.reset("203.0.113.7")
+
+	login := func(pass string) *httptest.ResponseRecorder {
+		form := url.Values{"name": {"pokladnik"}, "password": {pass}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "127.0.0.1:40000"
+		req.Header.Set("X-Real-IP", "203.0.113.7")
+		rec := httptest.NewRecorder()
+		loginHandler(rec, req)
+		return rec
+	}
+	for i := 0; i < userFreeFailures; i++ {
+		if loc := login("spatne").Header().Get("Location"); !strings.Contains(loc, "error=1") {
+			t.Fatalf("failure %d: %q", i+1, loc)
+		}
+	}
+	if loc := login("spatne").Header().Get("Location"); !strings.Contains(loc, "locked=1") {
+		t.Fatalf("not locked: %q", loc)
+	}
+	rec := login("heslo-pokladnika")
+	if loc := rec.Header().Get("Location"); !strings.Contains(loc, "locked=") || len(rec.Result().Cookies()) != 0 {
+		t.Fatalf("locked login went through: %q", loc)
+	}
+	if w := ipFailures.wait("203.0.113.7", time.Now()); w != 0 {
+		t.Errorf("IP locked after %d failures", userFreeFailures+1)
+	}
+
+	// A direct peer cannot pick its address through the header
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "198.51.100.1:5000"
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+	if ip := clientIP(req); ip != "198.51.100.1" {
+		t.Errorf("clientIP = %s", ip)
+	}
+	// Nor can one that only looks internal, like Docker's bridge gateway
+	// forwarding a published port
+	req.RemoteAddr = "172.17.0.1:5000"
+	if ip := clientIP(req); ip != "172.17.0.1" {
+		t.Errorf("clientIP from untrusted private peer = %s", ip)
+	}
+	req.RemoteAddr = "10.1.2.3:5000"
+	if ip := clientIP(req); ip != "203.0.113.7" {
+		t.Errorf("clientIP from trusted range = %s", ip)
+	}
+}
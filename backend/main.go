@@ -3,12 +3,10 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
 	_ "image/jpeg"
 	"image/png"
 	"io"
@@ -19,7 +17,6 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -83,19 +80,17 @@ func avgLuma(img image.Image) float64 {
 	return float64(sum) / float64(n)
 }
 
-// fitWithin returns destination size that fits source into max size, without upscaling
-func fitWithin(sw, sh, mw, mh int) (int, int) {
+// fitWithin returns destination size that fits source into max size, enlarging
+// small sources by at most maxUpscale (1: never)
+func fitWithin(sw, sh, mw, mh int, maxUpscale float64) (int, int) {
 	if sw <= 0 || sh <= 0 {
 		return 0, 0
 	}
-	if sw <= mw && sh <= mh {
-		return sw, sh
-	}
 	wr := float64(mw) / float64(sw)
 	hr := float64(mh) / float64(sh)
-	r := wr
-	if hr < wr {
-		r = hr
+	r := min(wr, hr, max(maxUpscale, 1))
+	if r == 1 {
+		return sw, sh
 	}
 	dw := int(float64(sw) * r)
 	dh := int(float64(sh) * r)
@@ -108,70 +103,62 @@ func fitWithin(sw, sh, mw, mh int) (int, int) {
 	return dw, dh
 }
 
-// scaleNearest performs nearest-neighbor downscaling from src to a new RGBA of size (dw, dh)
-func scaleNearest(src image.Image, dw, dh int) *image.RGBA {
-	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
-	sb := src.Bounds()
-	sw := sb.Dx()
-	sh := sb.Dy()
-	for y := 0; y < dh; y++ {
-		sy := sb.Min.Y + int(float64(y)*float64(sh)/float64(dh))
-		for x := 0; x < dw; x++ {
-			sx := sb.Min.X + int(float64(x)*float64(sw)/float64(dw))
-			dst.Set(x, y, src.At(sx, sy))
-		}
+// maxImagePixels bounds the declared size of uploaded images; a small file can
+// claim a huge canvas, so the header is checked before anything is decoded
+const maxImagePixels = 64 << 20
+
+// decodeImage decodes a supported image unless its header declares more than
+// maxImagePixels, and returns it with its format name
+func decodeImage(b []byte) (image.Image, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image: %w", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, "", fmt.Errorf("image too large: %dx%d pixels", cfg.Width, cfg.Height)
 	}
-	return dst
+	img, format, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image: %w", err)
+	}
+	return img, format, nil
 }
 
-// normalizeBlogImage decodes any supported image (PNG/JPEG) and writes a 1600x969 PNG with letterboxing (black/white)
-func normalizeBlogImage(r io.Reader, outPath string) error {
-	img, _, err := image.Decode(r)
+// normalizeBlogImage decodes any supported image (PNG/JPEG), turns it upright and writes a 1600x969 PNG fitted with fit
+// plus its responsive variants, which it returns
+func normalizeBlogImage(r io.Reader, outPath string, fit imageFit) ([]imageSource, error) {
+	b, err := io.ReadAll(r)
 	if err != nil {
-		return fmt.Errorf("decode image: %w", err)
-	}
-	// Choose background based on average luminance
-	l := avgLuma(img)
-	bg := color.Black
-	if l > 160 { // bright image -> white bg; tweak threshold as needed
-		bg = color.White
-	}
-	// Compute fitted size (no upscaling)
-	srcB := img.Bounds()
-	dw, dh := fitWithin(srcB.Dx(), srcB.Dy(), blogImgW, blogImgH)
-	var scaled image.Image
-	if dw == srcB.Dx() && dh == srcB.Dy() {
-		scaled = img
-	} else {
-		scaled = scaleNearest(img, dw, dh)
-	}
-	// Compose centered on canvas
-	canvas := image.NewRGBA(image.Rect(0, 0, blogImgW, blogImgH))
-	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
-	offX := (blogImgW - dw) / 2
-	offY := (blogImgH - dh) / 2
-	draw.Draw(canvas, image.Rect(offX, offY, offX+dw, offY+dh), scaled, scaled.Bounds().Min, draw.Over)
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	img, format, err := decodeImage(b)
+	if err != nil {
+		return nil, err
+	}
+	// Turn phone photos upright (EXIF orientation)
+	img = orientImage(img, readEXIF(b).Orientation)
+	canvas := composeBlogImage(img, fit)
 	// Write PNG atomically
 	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
-		return err
+		return nil, err
 	}
 	tmp := outPath + ".tmp"
 	f, err := os.Create(tmp)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	enc := png.Encoder{CompressionLevel: png.BestSpeed}
 	if err := enc.Encode(f, canvas); err != nil {
 		f.Close()
 		_ = os.Remove(tmp)
-		return fmt.Errorf("encode png: %w", err)
+		return nil, fmt.Errorf("encode png: %w", err)
 	}
 	f.Close()
 	_ = os.Remove(outPath)
 	if err := os.Rename(tmp, outPath); err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return writeImageVariants(canvas, outPath, format != "jpeg")
 }
 
 func staticPath() string {
@@ -230,91 +217,13 @@ type ClubDetail struct {
 	} `json:"competitions"`
 }
 
-// ---------------- Admin Basic Auth ----------------
-func adminCreds() (string, string) {
-	u := os.Getenv("ADMIN_USER")
-	p := os.Getenv("ADMIN_PASS")
-	if u == "" {
-		u = "info@tdvorak.dev"
-	}
-	if p == "" {
-		p = "%8s3Yad*!b3*t"
-	}
-	return u, p
-}
-
-func checkBasicAuth(r *http.Request) bool {
-	user, pass := adminCreds()
-	auth := r.Header.Get("Authorization")
-	if !strings.HasPrefix(auth, "Basic ") {
-		return false
-	}
-	b64 := strings.TrimPrefix(auth, "Basic ")
-	dec, err := base64.StdEncoding.DecodeString(b64)
-	if err != nil {
-		return false
-	}
-	parts := strings.SplitN(string(dec), ":", 2)
-	if len(parts) != 2 {
-		return false
-	}
-	return parts[0] == user && parts[1] == pass
-}
-
-func requireBasicAuth(w http.ResponseWriter) {
-	w.Header().Set("WWW-Authenticate", "Basic realm=admin")
-	http.Error(w, "unauthorized", http.StatusUnauthorized)
-}
-
-func basicAuth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !checkBasicAuth(r) {
-			requireBasicAuth(w)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
-// Compute next blog numeric ID by scanning blog/*.html
-func nextBlogID(siteRoot string) (int, error) {
-	blogDir := filepath.Join(siteRoot, "blog")
-	entries, err := os.ReadDir(blogDir)
-	if err != nil {
-		return 0, err
-	}
-	re := regexp.MustCompile(`^(\d{4})\.html$`)
-	max := -1
-	for _, e := range entries {
-		m := re.FindStringSubmatch(e.Name())
-		if len(m) == 2 {
-			if n, err := strconv.Atoi(m[1]); err == nil && n > max {
-				max = n
-			}
-		}
-	}
-	if max < 0 {
-		return 0, fmt.Errorf("no posts found to derive id")
-	}
-	return max + 1, nil
-}
-
-// Minimal HTML escaper for title
-func htmlEscape(s string) string {
-	s = strings.ReplaceAll(s, "&", "&amp;")
-	s = strings.ReplaceAll(s, "<", "&lt;")
-	s = strings.ReplaceAll(s, ">", "&gt;")
-	s = strings.ReplaceAll(s, `"`, "&quot;")
-	return s
-}
-
 // ---------------- YouTube: periodic refresh and persistence ----------------
 func videosScheduler(ctx context.Context) {
 	// Refresh once a day
 	for {
 		select {
 		case <-time.After(24 * time.Hour):
-			if err := refreshVideos(ctx); err != nil {
+			if _, err := refreshVideos(ctx); err != nil {
 				log.Printf("videos refresh error: %v", err)
 			}
 		case <-ctx.Done():
@@ -323,14 +232,16 @@ func videosScheduler(ctx context.Context) {
 	}
 }
 
-func refreshVideos(ctx context.Context) error {
+// refreshVideos fetches the newest videos; the source is "primary" when the
+// list was replaced and "kept" when the API returned none
+func refreshVideos(ctx context.Context) (string, error) {
 	client := &http.Client{Timeout: 30 * time.Second}
 	base := "https://youtube.tdvorak.dev"
 	ch := ytChannel()
 	u := base + "/channel_videos?channel=" + url.QueryEscape(ch)
 	var resp YTChannelResp
 	if err := getJSON(ctx, client, u, &resp); err != nil {
-		return fmt.Errorf("yt get: %w", err)
+		return "", fmt.Errorf("yt get: %w", err)
 	}
 	items := resp.Videos
 	if len(items) == 0 {
@@ -339,7 +250,7 @@ func refreshVideos(ctx context.Context) error {
 		existingCount := len(vc.data.Items)
 		vc.mu.RUnlock()
 		log.Printf("warn: yt api returned 0 videos; keeping %d existing videos", existingCount)
-		return nil
+		return "kept", nil
 	}
 	if len(items) > 5 {
 		items = items[:5]
@@ -352,7 +263,9 @@ func refreshVideos(ctx context.Context) error {
 	if err := writeVideosJSON(); err != nil {
 		log.Printf("warn: write videos json: %v", err)
 	}
-	return nil
+	// Video cards in posts show the new titles
+	refreshShortcodePages()
+	return "primary", nil
 }
 
 func writeVideosJSON() error {
@@ -448,30 +361,43 @@ func ytChannel() string {
 
 // BlogItem represents a simple blog card item for the homepage
 type BlogItem struct {
-	ID         string    `json:"id"`
-	Title      string    `json:"title"`
-	Slug       string    `json:"slug"`
-	Link       string    `json:"link"`
-	Image      string    `json:"image"`
-	MTime      time.Time `json:"mtime"`
-	Categories []string  `json:"categories,omitempty"`
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+	Link  string `json:"link"`
+	Image string `json:"image"`
+	// Sources are smaller JPEG and WebP versions of Image for srcset
+	Sources []imageSource `json:"sources,omitempty"`
+	MTime   time.Time     `json:"mtime"`
+	// PublishedAt is when the post went live; date filters apply to it
+	PublishedAt time.Time `json:"published_at"`
+	Description string    `json:"description,omitempty"`
+	Categories  []string  `json:"categories,omitempty"`
+	// Admin listings only
+	Author    string     `json:"author,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+// czechFolder replaces Czech characters with their ASCII equivalents
+var czechFolder = strings.NewReplacer(
+	"á", "a", "ä", "a", "č", "c", "ď", "d", "é", "e", "ě", "e", "í", "i", "ľ", "l",
+	"ň", "n", "ó", "o", "ö", "o", "ô", "o", "ř", "r", "š", "s", "ť", "t", "ú", "u",
+	"ů", "u", "ý", "y", "ž", "z",
+	"Á", "a", "Ä", "a", "Č", "c", "Ď", "d", "É", "e", "Ě", "e", "Í", "i", "Ľ", "l",
+	"Ň", "n", "Ó", "o", "Ö", "o", "Ô", "o", "Ř", "r", "Š", "s", "Ť", "t", "Ú", "u",
+	"Ů", "u", "Ý", "y", "Ž", "z",
+)
+
+// foldCzech lowercases s and strips Czech diacritics, so "Zápas" and "zapas"
+// compare equal
+func foldCzech(s string) string {
+	return czechFolder.Replace(strings.ToLower(s))
 }
 
 // generateSlug creates a URL-friendly slug from a title
 func generateSlug(title string) string {
-	slug := strings.ToLower(title)
-	// Replace Czech characters with their ASCII equivalents
-	replacements := map[string]string{
-		"á": "a", "ä": "a", "č": "c", "ď": "d", "é": "e", "ě": "e", "í": "i", "ľ": "l",
-		"ň": "n", "ó": "o", "ö": "o", "ô": "o", "ř": "r", "š": "s", "ť": "t", "ú": "u",
-		"ů": "u", "ý": "y", "ž": "z",
-		"Á": "a", "Ä": "a", "Č": "c", "Ď": "d", "É": "e", "Ě": "e", "Í": "i", "Ľ": "l",
-		"Ň": "n", "Ó": "o", "Ö": "o", "Ô": "o", "Ř": "r", "Š": "s", "Ť": "t", "Ú": "u",
-		"Ů": "u", "Ý": "y", "Ž": "z",
-	}
-	for czech, ascii := range replacements {
-		slug = strings.ReplaceAll(slug, czech, ascii)
-	}
+	slug := foldCzech(title)
 	// Remove any character that isn't alphanumeric, space, or hyphen
 	re := regexp.MustCompile(`[^a-z0-9\s-]`)
 	slug = re.ReplaceAllString(slug, "")
@@ -483,45 +409,41 @@ func generateSlug(title string) string {
 	return slug
 }
 
-// ensureUniqueSlug ensures the slug is unique by appending a number if needed
-func ensureUniqueSlug(siteRoot, baseSlug string) string {
-	blogDir := filepath.Join(siteRoot, "blog")
-	entries, err := os.ReadDir(blogDir)
-	if err != nil {
-		return baseSlug
-	}
-	existingSlugs := make(map[string]bool)
-	for _, e := range entries {
-		if !strings.HasSuffix(e.Name(), ".html") {
-			continue
+// parsePostStatus reads the status and publish_at form fields of new/edit
+// requests. Without an explicit status a publish time means "scheduled",
+// otherwise the post keeps current. publish_at accepts RFC 3339 or the
+// datetime-local format (Prague time).
+func parsePostStatus(r *http.Request, current string) (string, time.Time, error) {
+	status := strings.TrimSpace(r.FormValue("status"))
+	rawAt := strings.TrimSpace(r.FormValue("publish_at"))
+	var publishAt time.Time
+	if rawAt != "" {
+		t, err := time.Parse(time.RFC3339, rawAt)
+		if err != nil {
+			t, err = time.ParseInLocation("2006-01-02T15:04", rawAt, pragueLocation())
 		}
-		// Extract slug from filename if it follows the new pattern
-		name := strings.TrimSuffix(e.Name(), ".html")
-		// Check if it's a slug-based filename (contains letters, not just numbers)
-		if regexp.MustCompile(`[a-z]`).MatchString(name) {
-			existingSlugs[name] = true
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid publish_at")
 		}
+		publishAt = t
 	}
-	if !existingSlugs[baseSlug] {
-		return baseSlug
-	}
-	// Try baseSlug-2, baseSlug-3, etc.
-	for i := 2; i < 100; i++ {
-		testSlug := fmt.Sprintf("%s-%d", baseSlug, i)
-		if !existingSlugs[testSlug] {
-			return testSlug
+	if status == "" {
+		status = current
+		if !publishAt.IsZero() {
+			status = postScheduled
 		}
 	}
-	// Fallback to timestamp
-	return fmt.Sprintf("%s-%d", baseSlug, time.Now().Unix())
+	if !validPostStatus(status) {
+		return "", time.Time{}, fmt.Errorf("invalid status")
+	}
+	if status == postScheduled && publishAt.IsZero() {
+		return "", time.Time{}, fmt.Errorf("missing publish_at for scheduled post")
+	}
+	return status, publishAt, nil
 }
 
-func extractCategories(path string) []string {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return nil
-	}
-	s := string(b)
+// extractCategoriesFromContent extracts category meta tags from HTML content string
+func extractCategoriesFromContent(s string) []string {
 	// match <meta name="category" content="Category">
 	re := regexp.MustCompile(`(?is)<meta name="category" content="([^"]+)"`)
 	matches := re.FindAllStringSubmatch(s, -1)
@@ -532,13 +454,8 @@ func extractCategories(path string) []string {
 	return categories
 }
 
-// extractAnnotation extracts the annotation from blog HTML meta tags
-func extractAnnotation(path string) string {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return ""
-	}
-	s := string(b)
+// extractAnnotationFromContent extracts the annotation from HTML content string
+func extractAnnotationFromContent(s string) string {
 	// Try to find description in meta tag first
 	re := regexp.MustCompile(`(?is)<meta name="description" content="([^"]+)"`)
 	m := re.FindStringSubmatch(s)
@@ -548,13 +465,8 @@ func extractAnnotation(path string) string {
 	return ""
 }
 
-// extractContentMode extracts the content mode from blog HTML meta tags
-func extractContentMode(path string) string {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return ""
-	}
-	s := string(b)
+// extractContentModeFromContent extracts the content mode from HTML content string
+func extractContentModeFromContent(s string) string {
 	// Try to find content_mode in meta tag first
 	re := regexp.MustCompile(`(?is)<meta name="content_mode" content="([^"]+)"`)
 	m := re.FindStringSubmatch(s)
@@ -565,15 +477,6 @@ func extractContentMode(path string) string {
 	return "visual"
 }
 
-// extractSlug extracts the slug from blog HTML meta tags or generates from filename
-func extractSlug(path, filename string) string {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return ""
-	}
-	return extractSlugFromContent(string(b))
-}
-
 // extractSlugFromContent extracts the slug from HTML content string
 func extractSlugFromContent(htmlContent string) string {
 	re := regexp.MustCompile(`(?is)<meta name="slug" content="([^"]+)"`)
@@ -600,126 +503,108 @@ func extractBlogID(path, filename string) string {
 	return strings.TrimSuffix(filename, ".html")
 }
 
+// listLatestBlogs returns up to limit posts (0 = all), newest first, from the post index.
+// The process-wide index is used when siteRoot is the served site; any other root
+// (tests, tools) gets a throwaway index built from disk.
 func listLatestBlogs(siteRoot string, limit int) ([]BlogItem, error) {
-	// Use the siteRoot path where blogs are actually located
-	blogDir := filepath.Join(siteRoot, "blog")
+	st, err := blogStoreFor(siteRoot)
+	if err != nil {
+		return nil, err
+	}
+	return st.list(limit), nil
+}
 
-	// For local development, you can override with environment variable
-	if envPath := os.Getenv("REMOTE_BLOG_DIR"); envPath != "" {
-		blogDir = envPath
+// blogStoreFor returns the post store of siteRoot: the process-wide one for the
+// served site, otherwise a throwaway store built from disk
+func blogStoreFor(siteRoot string) (*postStore, error) {
+	blogDir := blogDirFor(siteRoot)
+	if posts.servesDir(blogDir) {
+		return &posts, nil
+	}
+	st := &postStore{}
+	if err := st.open(blogDir, ""); err != nil {
+		return nil, err
 	}
+	return st, nil
+}
 
-	// If blog directory doesn't exist, return error
-	if _, err := os.Stat(blogDir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("blog directory not found: %s. Set REMOTE_BLOG_DIR environment variable or ensure blog directory exists", blogDir)
+// parseBlogDate accepts RFC 3339 or a plain date (midnight Prague time)
+func parseBlogDate(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
 	}
+	return time.ParseInLocation("2006-01-02", v, pragueLocation())
+}
 
-	imgDir := filepath.Join(filepath.Dir(blogDir), "img", "blog")
-	entries, err := os.ReadDir(blogDir)
-	if err != nil {
-		return nil, fmt.Errorf("readdir blog: %w", err)
-	}
-	// Match both numeric (0001.html) and slug-based filenames
-	re := regexp.MustCompile(`^(\d{4}|[a-z0-9-]+)\.html$`)
-	var items []BlogItem
-	seenIDs := make(map[string]bool) // Track seen IDs to avoid duplicates
-	for _, e := range entries {
-		name := e.Name()
-		if !re.MatchString(name) {
-			continue
-		}
-		id := strings.TrimSuffix(name, ".html")
-
-		// Title and categories extraction from blog HTML
-		blogPath := filepath.Join(blogDir, name)
-		title := extractTitle(blogPath)
-		slug := extractSlug(blogPath, name)
-		cats := extractCategories(blogPath)
-
-		// Determine canonical ID: prefer numeric when available.
-		// This ensures consistent item identity regardless of whether the
-		// numeric or slug filename is encountered first in the directory.
-		canonicalID := id
-		if regexp.MustCompile(`^[a-z]`).MatchString(id) {
-			numericFiles, _ := filepath.Glob(filepath.Join(blogDir, "????.html"))
-			for _, numericFile := range numericFiles {
-				numericID := strings.TrimSuffix(filepath.Base(numericFile), ".html")
-				numericPath := filepath.Join(blogDir, numericFile)
-				numericSlug := extractSlug(numericPath, numericFile)
-				if numericSlug == id {
-					canonicalID = numericID
-					break
-				}
-			}
+// parseBlogQuery reads the category, after, before and sort parameters of the
+// blog list endpoints
+func parseBlogQuery(r *http.Request) (postQuery, error) {
+	v := r.URL.Query()
+	q := postQuery{Category: v.Get("category"), Sort: v.Get("sort")}
+	if q.Sort != "" && !postSorts[q.Sort] {
+		return q, fmt.Errorf("invalid sort %q (newest, oldest, updated, title)", q.Sort)
+	}
+	var err error
+	if s := v.Get("after"); s != "" {
+		if q.After, err = parseBlogDate(s); err != nil {
+			return q, fmt.Errorf("invalid after date")
 		}
-
-		// Skip if this canonical ID or its slug was already processed (deduplication)
-		if seenIDs[canonicalID] || (slug != "" && seenIDs[slug]) {
-			continue
+	}
+	if s := v.Get("before"); s != "" {
+		if q.Before, err = parseBlogDate(s); err != nil {
+			return q, fmt.Errorf("invalid before date")
 		}
+	}
+	return q, nil
+}
 
-		// Mark both canonical ID and slug as seen
-		seenIDs[canonicalID] = true
-		if slug != "" {
-			seenIDs[slug] = true
-		}
-		// Determine mod time - prefer image modtime if exists, else html
-		mtime := time.Time{}
-		htmlInfo, err1 := os.Stat(filepath.Join(blogDir, name))
-		if err1 == nil {
-			mtime = htmlInfo.ModTime()
-		}
-		// For image path, canonicalID is already numeric when a numeric file exists
-		imageID := canonicalID
-		if imgInfo, err2 := os.Stat(filepath.Join(imgDir, imageID+".png")); err2 == nil {
-			// If image is newer, use that as a proxy for recency
-			if imgInfo.ModTime().After(mtime) {
-				mtime = imgInfo.ModTime()
-			}
-		}
-		// Use slug-based link if slug exists and is not just numeric, otherwise use canonical numeric ID
-		var link string
-		if slug != "" && regexp.MustCompile(`[a-z]`).MatchString(slug) {
-			link = "/blog/" + slug
-		} else {
-			link = "/blog/" + canonicalID + ".html"
-		}
-		items = append(items, BlogItem{
-			ID:         canonicalID,
-			Title:      title,
-			Slug:       slug,
-			Link:       link,
-			Image:      "/img/blog/" + imageID + ".png",
-			MTime:      mtime,
-			Categories: cats,
-		})
+// writeBlogList answers the public blog list endpoints. Without page or
+// per_page the response is the plain array (up to limit, default
+// defaultLimit) older clients expect; with them it is a page object carrying
+// the total count.
+func writeBlogList(w http.ResponseWriter, r *http.Request, defaultLimit int) {
+	q, err := parseBlogQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	sort.Slice(items, func(i, j int) bool {
-		// Always prefer numeric ID descending (higher ID = newer post).
-		// Numeric IDs monotonically increase via nextBlogID, so they are
-		// the authoritative ordering regardless of file MTime.
-		ii, err1 := strconv.Atoi(items[i].ID)
-		jj, err2 := strconv.Atoi(items[j].ID)
-		if err1 == nil && err2 == nil {
-			return ii > jj
-		}
-		// If only one item has a numeric ID, it is newer
-		if err1 == nil {
-			return true
-		}
-		if err2 == nil {
-			return false
+	st, err := blogStoreFor(staticPath())
+	if err != nil {
+		log.Printf("blog list error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	items := st.query(q)
+	v := r.URL.Query()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if v.Get("page") == "" && v.Get("per_page") == "" {
+		limit := defaultLimit
+		if n, err := strconv.Atoi(v.Get("limit")); err == nil && n > 0 {
+			limit = n
 		}
-		// Both non-numeric: fall back to MTime, then string comparison
-		if !items[i].MTime.Equal(items[j].MTime) {
-			return items[i].MTime.After(items[j].MTime)
+		if len(items) > limit {
+			items = items[:limit]
 		}
-		return items[i].ID > items[j].ID
+		_ = json.NewEncoder(w).Encode(items)
+		return
+	}
+	page, perPage := 1, 12
+	if n, err := strconv.Atoi(v.Get("page")); err == nil && n > 0 {
+		page = n
+	}
+	if n, err := strconv.Atoi(v.Get("per_page")); err == nil && n > 0 {
+		perPage = min(n, 100)
+	}
+	total := len(items)
+	start := min((page-1)*perPage, total)
+	end := min(start+perPage, total)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"items":    items[start:end],
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"pages":    (total + perPage - 1) / perPage,
 	})
-	if limit > 0 && len(items) > limit {
-		items = items[:limit]
-	}
-	return items, nil
 }
 
 // validateBlogOrdering performs a startup health check to catch ordering regressions early.
@@ -742,13 +627,8 @@ func validateBlogOrdering(siteRoot string) error {
 	return nil
 }
 
-// extractTitle finds the first <h1>...</h1> and returns its inner text (very simple, best-effort)
-func extractTitle(path string) string {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return ""
-	}
-	s := string(b)
+// extractTitleFromContent finds the first <h1>...</h1> and returns its inner text (very simple, best-effort)
+func extractTitleFromContent(s string) string {
 	// match <h1 ...>Title</h1>
 	re := regexp.MustCompile(`(?is)<h1[^>]*>(.*?)</h1>`) // non-greedy
 	m := re.FindStringSubmatch(s)
@@ -763,6 +643,30 @@ func extractTitle(path string) string {
 	return ""
 }
 
+// extractBodyFromContent returns the inner HTML of <div class="text lte-text-page clearfix">,
+// following nested divs so post bodies containing their own <div>s are not cut short
+func extractBodyFromContent(s string) string {
+	const open = `<div class="text lte-text-page clearfix">`
+	start := strings.Index(s, open)
+	if start < 0 {
+		return ""
+	}
+	start += len(open)
+	reDiv := regexp.MustCompile(`(?i)<(/?)div\b`)
+	depth := 1
+	for _, m := range reDiv.FindAllStringSubmatchIndex(s[start:], -1) {
+		if m[3] > m[2] {
+			depth--
+		} else {
+			depth++
+		}
+		if depth == 0 {
+			return strings.TrimSpace(s[start : start+m[0]])
+		}
+	}
+	return strings.TrimSpace(s[start:])
+}
+
 type ClubTable struct {
 	Name         string `json:"name"`
 	ClubID       string `json:"club_id"`
@@ -865,11 +769,21 @@ var videosPostLimiter rateLimiter
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+	// Admin accounts: refuse to run without one or on the old default password
+	if err := users.open(usersPath()); err != nil {
+		log.Fatalf("admin accounts: %v", err)
+	}
+	if err := users.checkStartup(); err != nil {
+		log.Fatalf("admin accounts: %v", err)
+	}
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
 	// initial fetch
-	if err := refresh(ctx); err != nil {
+	if _, err := refresh(ctx); err != nil {
 		log.Printf("initial refresh error: %v", err)
 	}
 
@@ -883,10 +797,22 @@ func main() {
 	}
 
 	// Initial videos fetch on startup to warm cache
-	if err := refreshVideos(ctx); err != nil {
+	if _, err := refreshVideos(ctx); err != nil {
 		log.Printf("initial videos refresh error: %v", err)
 	}
 
+	// Load the blog post store (imports legacy pages, re-renders stale ones)
+	if err := posts.open(blogDirFor(staticPath()), postsPath()); err != nil {
+		log.Printf("blog index: %v", err)
+	}
+	// Media library, referenced by the [media] and [gallery] shortcodes
+	if err := media.open(mediaDirFor(staticPath()), mediaPath()); err != nil {
+		log.Printf("media library: %v", err)
+	}
+	// The caches were refreshed before the store was open
+	refreshShortcodePages()
+	go blogPublisher(ctx)
+
 	// Startup validation: warn if blog ordering looks wrong
 	if err := validateBlogOrdering(staticPath()); err != nil {
 		log.Printf("blog ordering validation: %v", err)
@@ -894,36 +820,22 @@ func main() {
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
 	mux.HandleFunc("/data/club.json", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
 		switch r.Method {
 		case http.MethodGet:
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
 			c.mu.RLock()
 			defer c.mu.RUnlock()
 			_ = json.NewEncoder(w).Encode(c.data)
-		case http.MethodDelete:
-			// delete on-disk file and clear in-memory cache
-			path := dataPath()
-			_ = os.Remove(path)
-			c.mu.Lock()
-			c.data = Combined{}
-			c.mu.Unlock()
-			// trigger immediate refresh so next GET has fresh data
-			if err := refresh(r.Context()); err != nil {
-				log.Printf("manual refresh after delete failed: %v", err)
-			}
-			w.WriteHeader(http.StatusNoContent)
 		default:
+			// Purging moved to DELETE /api/admin/cache/club
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
 	})
 	mux.HandleFunc("/data/club.js", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
 		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
 		c.mu.RLock()
 		payload, _ := json.Marshal(c.data)
@@ -935,26 +847,44 @@ func main() {
 
 	// Blog list JSON for frontend
 	mux.HandleFunc("/data/blog-list.json", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		limit := 50 // Default limit for blog list
-		if q := r.URL.Query().Get("limit"); q != "" {
-			if n, err := strconv.Atoi(q); err == nil && n > 0 {
-				limit = n
-			}
+		writeBlogList(w, r, 50) // Default limit for blog list
+	})
+
+	// Blog API: latest posts; filters and paging as in writeBlogList
+	mux.HandleFunc("/api/blog/latest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeBlogList(w, r, 5)
+	})
+
+	// Blog categories with the number of published posts in each
+	mux.HandleFunc("/api/blog/categories", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
 		}
-		items, err := listLatestBlogs(staticPath(), limit)
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		st, err := blogStoreFor(staticPath())
 		if err != nil {
-			log.Printf("blog-list.json error: %v", err)
+			log.Printf("blog categories error: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		_ = json.NewEncoder(w).Encode(items)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(st.categories())
 	})
 
-	// Blog API: latest N posts from filesystem
-	mux.HandleFunc("/api/blog/latest", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
+	// Blog search: ?q=&limit= over titles, annotations and text of published posts
+	mux.HandleFunc("/api/blog/search", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -963,25 +893,38 @@ func main() {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		limit := 5
-		if q := r.URL.Query().Get("limit"); q != "" {
-			if n, err := strconv.Atoi(q); err == nil && n > 0 {
-				limit = n
-			}
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		if q == "" {
+			http.Error(w, "missing q", http.StatusBadRequest)
+			return
 		}
-		items, err := listLatestBlogs(staticPath(), limit)
-		if err != nil {
-			log.Printf("listLatestBlogs error: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		if len(q) > 200 {
+			http.Error(w, "query too long", http.StatusBadRequest)
 			return
 		}
+		limit := 10
+		if v := r.URL.Query().Get("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 50 {
+				limit = n
+			}
+		}
+		results, total := posts.search(q, limit)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(items)
+		_ = json.NewEncoder(w).Encode(map[string]any{"query": q, "total": total, "results": results})
 	})
 
+	// News feeds: RSS, Atom and per-category RSS (/feed/{category}.xml)
+	mux.HandleFunc("/feed.xml", feedHandler(false))
+	mux.HandleFunc("/atom.xml", feedHandler(true))
+	mux.HandleFunc("/feed/", feedHandler(false))
+
+	// Generated sitemap (or sitemap index with /sitemaps/<n>.xml parts) and robots.txt
+	mux.HandleFunc("/sitemap.xml", sitemapHandler)
+	mux.HandleFunc("/sitemaps/", sitemapHandler)
+	mux.HandleFunc("/robots.txt", robotsHandler)
+
 	// Videos API
 	mux.HandleFunc("/api/videos/latest", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -995,7 +938,7 @@ func main() {
 			vc.mu.RUnlock()
 			if len(items) == 0 {
 				// lazy refresh if empty
-				if err := refreshVideos(r.Context()); err != nil {
+				if _, err := refreshVideos(r.Context()); err != nil {
 					log.Printf("refreshVideos error: %v", err)
 				}
 				vc.mu.RLock()
@@ -1010,26 +953,14 @@ func main() {
 				Channel   string    `json:"channel"`
 				Items     []YTVideo `json:"items"`
 			}{FetchedAt: fetched, Channel: channel, Items: items})
-		case http.MethodPost:
-			// rate limit: 5 requests per minute for manual refresh
-			if !videosPostLimiter.Allow(time.Now(), 5, time.Minute) {
-				http.Error(w, "rate limit: max 5 refresh per minute", http.StatusTooManyRequests)
-				return
-			}
-			if err := refreshVideos(r.Context()); err != nil {
-				log.Printf("manual refreshVideos error: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			w.WriteHeader(http.StatusNoContent)
 		default:
+			// Manual refresh moved to POST /api/admin/cache/videos/refresh
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
 	})
 
 	// Serve raw persisted videos json for debugging/preview
 	mux.HandleFunc("/data/videos.json", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
@@ -1045,9 +976,7 @@ func main() {
 
 	// Blog creation API (admin)
 	mux.HandleFunc("/api/blog/new", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
-		if !checkBasicAuth(r) {
-			requireBasicAuth(w)
+		if !requireRole(w, r, roleEditor) {
 			return
 		}
 		if r.Method == http.MethodOptions {
@@ -1058,7 +987,7 @@ func main() {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		// Expect multipart form with: title, slug, annotation, content_mode, content (HTML), image (png), categories (comma-separated)
+		// Expect multipart form with: title, slug, annotation, content_mode, content (HTML or Markdown), image (png), categories (comma-separated)
 		if err := r.ParseMultipartForm(20 << 20); err != nil { // 20MB
 			http.Error(w, "invalid form", http.StatusBadRequest)
 			return
@@ -1067,7 +996,16 @@ func main() {
 		slugInput := strings.TrimSpace(r.FormValue("slug"))
 		annotation := strings.TrimSpace(r.FormValue("annotation"))
 		contentMode := strings.TrimSpace(r.FormValue("content_mode"))
-		htmlContent := strings.TrimSpace(r.FormValue("content"))
+		// Default content mode to visual if not specified
+		if contentMode == "" {
+			contentMode = contentVisual
+		}
+		// Stored sanitized, so editors see what the public page shows
+		htmlContent, markdownSource, err := postContent(contentMode, r.FormValue("content"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		catsRaw := strings.TrimSpace(r.FormValue("categories"))
 		var cats []string
 		if catsRaw != "" {
@@ -1082,9 +1020,19 @@ func main() {
 			http.Error(w, "missing title or content", http.StatusBadRequest)
 			return
 		}
-		// Default content mode to visual if not specified
-		if contentMode == "" {
-			contentMode = "visual"
+		if slugInput != "" && !validSlug(slugInput) {
+			http.Error(w, "invalid slug: use a-z, 0-9 and -, not four digits", http.StatusBadRequest)
+			return
+		}
+		status, publishAt, err := parsePostStatus(r, postPublished)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fit, _, err := parseImageFit(r, defaultImageFit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 		f, fh, err := r.FormFile("image")
 		if err != nil {
@@ -1099,104 +1047,57 @@ func main() {
 			return
 		}
 		site := staticPath()
-		// Determine next ID
-		nid, err := nextBlogID(site)
-		if err != nil {
-			http.Error(w, "failed to compute next id", http.StatusInternalServerError)
+		// Reserve the ID and slug before anything is written, so a concurrent
+		// create cannot get the same ones
+		idStr, finalSlug, err := posts.create(slugInput, title)
+		if errors.Is(err, errSlugTaken) {
+			http.Error(w, err.Error(), http.StatusConflict)
 			return
 		}
-		idStr := fmt.Sprintf("%04d", nid)
-
-		// Determine slug
-		var finalSlug string
-		if slugInput != "" {
-			// Use provided slug (already validated by frontend)
-			finalSlug = slugInput
-		} else {
-			// Generate from title
-			baseSlug := generateSlug(title)
-			finalSlug = ensureUniqueSlug(site, baseSlug)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		// Reservations of stored posts are already gone
+		defer posts.release(idStr)
 
-		// Write image (normalize to 1600x969 with letterboxing)
+		// Write image (normalize to 1600x969 with the chosen fit)
 		imgDir := filepath.Join(site, "img", "blog")
 		if err := os.MkdirAll(imgDir, 0755); err != nil {
 			http.Error(w, "storage error: img dir", http.StatusInternalServerError)
 			return
 		}
 		imgPath := filepath.Join(imgDir, idStr+".png")
-		if err := normalizeBlogImage(f, imgPath); err != nil {
+		rec := postRecord{
+			ID:          idStr,
+			Slug:        finalSlug,
+			Title:       title,
+			Annotation:  annotation,
+			Categories:  cats,
+			ContentMode: contentMode,
+			Body:        htmlContent,
+			Markdown:    markdownSource,
+			Image:       "/img/blog/" + idStr + ".png",
+			Status:      status,
+			PublishAt:   publishAt,
+			Author:      requestUser(r),
+			UpdatedBy:   requestUser(r),
+		}
+		if err := saveBlogImage(f, &rec, imgPath, fit); err != nil {
 			http.Error(w, "image processing failed", http.StatusInternalServerError)
 			return
 		}
-		// Read template and replace
-		tplPath := filepath.Join(site, "blog", "0030.html")
-		tplBytes, err := os.ReadFile(tplPath)
+		// Store the post record and render its page
+		saved, err := posts.put(rec)
 		if err != nil {
-			http.Error(w, "template not found", http.StatusInternalServerError)
-			return
-		}
-		s := string(tplBytes)
-		// Replace H1 title inside page header (match when lte-header is among multiple classes)
-		reH1 := regexp.MustCompile(`(?is)<h1[^>]*class="[^"]*\blte-header\b[^"]*"[^>]*>.*?</h1>`)
-		s = reH1.ReplaceAllString(s, "<h1 class=\"lte-header\">"+htmlEscape(title)+"</h1>")
-		// Replace main hero image to point to new id
-		reImg := regexp.MustCompile(`(?is)src=\"\.\./img/blog/\d{4}\.png\"`)
-		s = reImg.ReplaceAllString(s, "src=\"../img/blog/"+idStr+".png\"")
-		// Replace post top image similarly if found with different quoting
-		reImg2 := regexp.MustCompile(`(?is)src=\"\.\./img/blog/\d{4}\.png\"`)
-		s = reImg2.ReplaceAllString(s, "src=\"../img/blog/"+idStr+".png\"")
-		// Replace the main content inside <div class="text lte-text-page clearfix"> ... </div>
-		reContent := regexp.MustCompile(`(?is)<div class="text lte-text-page clearfix">[\s\S]*?</div>`)
-		s = reContent.ReplaceAllString(s, "<div class=\"text lte-text-page clearfix\">\n"+htmlContent+"\n</div>")
-		// Inject categories as <meta name="category" content="..."> before </head>
-		if len(cats) > 0 {
-			var meta string
-			for _, c := range cats {
-				meta += "<meta name=\"category\" content=\"" + htmlEscape(c) + "\">\n"
-			}
-			reHead := regexp.MustCompile(`(?is)</head>`)
-			s = reHead.ReplaceAllString(s, meta+"</head>")
-		}
-		// Inject slug as <meta name="slug" content="..."> before </head>
-		slugMeta := "<meta name=\"slug\" content=\"" + htmlEscape(finalSlug) + "\">\n"
-		reHeadSlug := regexp.MustCompile(`(?is)</head>`)
-		s = reHeadSlug.ReplaceAllString(s, slugMeta+"</head>")
-		// Inject annotation as <meta name="description" content="..."> before </head>
-		if annotation != "" {
-			annotationMeta := "<meta name=\"description\" content=\"" + htmlEscape(annotation) + "\">\n"
-			reHeadAnnotation := regexp.MustCompile(`(?is)</head>`)
-			s = reHeadAnnotation.ReplaceAllString(s, annotationMeta+"</head>")
-		}
-		// Inject content mode as <meta name="content_mode" content="..."> before </head>
-		contentModeMeta := "<meta name=\"content_mode\" content=\"" + htmlEscape(contentMode) + "\">\n"
-		reHeadContentMode := regexp.MustCompile(`(?is)</head>`)
-		s = reHeadContentMode.ReplaceAllString(s, contentModeMeta+"</head>")
-		// Inject Rybbit analytics script before </head>
-		rybbitScript := "<script src=\"https://rybbit.tdvorak.dev/api/script.js\" data-site-id=\"d40b7ffffffa\" defer></script>\n"
-		reHeadRybbit := regexp.MustCompile(`(?is)</head>`)
-		s = reHeadRybbit.ReplaceAllString(s, rybbitScript+"</head>")
-
-		// Write new blog html with both numeric and slug filenames
-		blogDir := filepath.Join(site, "blog")
-		if err := os.MkdirAll(blogDir, 0755); err != nil {
-			http.Error(w, "storage error: blog dir", http.StatusInternalServerError)
-			return
-		}
-
-		// Write numeric file (for backward compatibility)
-		htmlPath := filepath.Join(blogDir, idStr+".html")
-		if err := os.WriteFile(htmlPath, []byte(s), 0644); err != nil {
+			log.Printf("blog new: %v", err)
 			http.Error(w, "cannot write blog (is STATIC_PATH read-only?)", http.StatusInternalServerError)
 			return
 		}
-
-		// Write slug file (new format)
-		slugPath := filepath.Join(blogDir, finalSlug+".html")
-		if err := os.WriteFile(slugPath, []byte(s), 0644); err != nil {
-			http.Error(w, "cannot write slug blog (is STATIC_PATH read-only?)", http.StatusInternalServerError)
-			return
+		if _, err := revisions.record(saved, imgPath, requestUser(r), "create"); err != nil {
+			log.Printf("warn: blog revision %s: %v", idStr, err)
 		}
+		audit.record(r, auditPostCreate, saved.Title, idStr)
 
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		_ = json.NewEncoder(w).Encode(map[string]any{
@@ -1204,13 +1105,13 @@ func main() {
 			"slug":    finalSlug,
 			"link":    "/blog/" + finalSlug,
 			"image":   "/img/blog/" + idStr + ".png",
+			"status":  status,
 			"message": "created",
 		})
 	})
 
 	// Blog slug resolution: convert slug to numeric ID
 	mux.HandleFunc("/api/blog/resolve", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
@@ -1225,41 +1126,25 @@ func main() {
 			http.Error(w, "invalid slug format", http.StatusBadRequest)
 			return
 		}
-		site := staticPath()
-		blogDir := filepath.Join(site, "blog")
-
-		// First try to find slug-based file
-		slugPath := filepath.Join(blogDir, slug+".html")
-		if b, err := os.ReadFile(slugPath); err == nil {
-			s := string(b)
-			// Try to find the corresponding numeric file
-			entries, _ := os.ReadDir(blogDir)
-			for _, e := range entries {
-				name := e.Name()
-				if !regexp.MustCompile(`^\d{4}\.html$`).MatchString(name) {
-					continue
-				}
-				numericPath := filepath.Join(blogDir, name)
-				numericContent, _ := os.ReadFile(numericPath)
-				if string(numericContent) == s {
-					// Found matching numeric file
-					numericID := strings.TrimSuffix(name, ".html")
-					w.Header().Set("Content-Type", "application/json; charset=utf-8")
-					_ = json.NewEncoder(w).Encode(map[string]any{"id": numericID, "slug": slug})
-					return
-				}
+		id, ok := posts.lookupSlug(slug)
+		if !ok {
+			// Former slugs resolve to the post under its current slug
+			if id, ok = posts.lookupAlias(slug); ok {
+				p, _ := posts.get(id)
+				slug = p.Slug
 			}
 		}
-
-		// If not found, return 404
-		http.Error(w, "slug not found", http.StatusNotFound)
+		if !ok {
+			http.Error(w, "slug not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "slug": slug})
 	})
 
-	// Blog fetch (admin): returns title, content html, image for editing
+	// Blog fetch (admin): returns title, content (HTML or Markdown source), image for editing
 	mux.HandleFunc("/api/blog/get", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
-		if !checkBasicAuth(r) {
-			requireBasicAuth(w)
+		if !requireRole(w, r, roleViewer) {
 			return
 		}
 		if r.Method != http.MethodGet {
@@ -1272,44 +1157,50 @@ func main() {
 			http.Error(w, "invalid id", http.StatusBadRequest)
 			return
 		}
-		path := filepath.Join(staticPath(), "blog", id+".html")
-		b, err := os.ReadFile(path)
-		if err != nil {
+		p, ok := posts.get(id)
+		if !ok {
 			http.Error(w, "not found", http.StatusNotFound)
 			return
 		}
-		s := string(b)
-		reH1 := regexp.MustCompile(`(?is)<h1[^>]*class="[^"]*\blte-header\b[^"]*"[^>]*>(.*?)</h1>`)
-		m := reH1.FindStringSubmatch(s)
-		title := ""
-		if len(m) >= 2 {
-			// strip HTML tags inside, if any
-			inner := m[1]
-			// remove any nested tags crudely
-			inner = regexp.MustCompile(`(?is)<[^>]+>`).ReplaceAllString(inner, "")
-			inner = strings.TrimSpace(inner)
-			title = inner
-		}
-		reContent := regexp.MustCompile(`(?is)<div class="text lte-text-page clearfix">([\s\S]*?)</div>`)
-		mc := reContent.FindStringSubmatch(s)
-		content := ""
-		if len(mc) >= 2 {
-			content = strings.TrimSpace(mc[1])
-		}
-		cats := extractCategories(path)
-		slug := extractSlug(path, id+".html")
-		annotation := extractAnnotation(path)
-		contentMode := extractContentMode(path)
-		img := "/img/blog/" + id + ".png"
+		resp := map[string]any{"id": p.ID, "title": p.Title, "slug": p.Slug, "annotation": p.Annotation, "content_mode": p.ContentMode, "image": p.Image, "image_fit": p.imageFit(), "image_meta": p.ImageMeta, "categories": p.Categories, "status": p.Status, "publish_at": p.PublishAt, "aliases": p.Aliases, "author": p.Author, "updated_by": p.UpdatedBy}
+		// Markdown posts are edited as their source, not the rendered HTML
+		if p.ContentMode == contentMarkdown {
+			resp["content_markdown"] = p.Markdown
+		} else {
+			resp["content_html"] = p.Body
+		}
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "title": title, "slug": slug, "annotation": annotation, "content_mode": contentMode, "content_html": content, "image": img, "categories": cats})
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+
+	// Blog original image (admin): the kept upload the hero image was cut
+	// from, for choosing a focal point
+	mux.HandleFunc("/api/blog/original", func(w http.ResponseWriter, r *http.Request) {
+		if !requireRole(w, r, roleViewer) {
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p, ok := posts.get(strings.TrimSpace(r.URL.Query().Get("id")))
+		if !ok || p.imageFit().Original == "" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		f, err := os.Open(originalImagePath(p.ID, p.imageFit().Original))
+		if err != nil {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Cache-Control", "private, no-store")
+		http.ServeContent(w, r, "", time.Time{}, f)
 	})
 
 	// Blog edit (admin): update title/content and optionally replace image
 	mux.HandleFunc("/api/blog/edit", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
-		if !checkBasicAuth(r) {
-			requireBasicAuth(w)
+		if !requireRole(w, r, roleEditor) {
 			return
 		}
 		if r.Method == http.MethodOptions {
@@ -1320,7 +1211,7 @@ func main() {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		// Expect multipart form with: title, slug, annotation, content_mode, content (HTML), image (png), categories (comma-separated)
+		// Expect multipart form with: title, slug, annotation, content_mode, content (HTML or Markdown), image (png), categories (comma-separated)
 		if err := r.ParseMultipartForm(25 << 20); err != nil {
 			http.Error(w, "invalid form", http.StatusBadRequest)
 			return
@@ -1335,7 +1226,16 @@ func main() {
 		slugInput := strings.TrimSpace(r.FormValue("slug"))
 		annotation := strings.TrimSpace(r.FormValue("annotation"))
 		contentMode := strings.TrimSpace(r.FormValue("content_mode"))
-		htmlContent := strings.TrimSpace(r.FormValue("content"))
+		// Default content mode to visual if not specified
+		if contentMode == "" {
+			contentMode = contentVisual
+		}
+		// Stored sanitized, so editors see what the public page shows
+		htmlContent, markdownSource, err := postContent(contentMode, r.FormValue("content"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		catsRaw := strings.TrimSpace(r.FormValue("categories"))
 		var cats []string
 		if catsRaw != "" {
@@ -1350,11 +1250,35 @@ func main() {
 			http.Error(w, "missing title or content", http.StatusBadRequest)
 			return
 		}
-		// Default content mode to visual if not specified
-		if contentMode == "" {
-			contentMode = "visual"
+		if slugInput != "" && !validSlug(slugInput) {
+			http.Error(w, "invalid slug: use a-z, 0-9 and -, not four digits", http.StatusBadRequest)
+			return
+		}
+		p, ok := posts.get(id)
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		status, publishAt, err := parsePostStatus(r, p.Status)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if slugInput != "" && slugInput != p.Slug && posts.slugTaken(slugInput, id) {
+			http.Error(w, "slug already in use", http.StatusConflict)
+			return
 		}
 		site := staticPath()
+		imgPath := filepath.Join(site, "img", "blog", id+".png")
+		// Keep the pre-edit state of posts that predate revision history
+		if err := revisions.ensureBaseline(p, imgPath); err != nil {
+			log.Printf("warn: blog revision baseline %s: %v", id, err)
+		}
+		fit, fitSet, err := parseImageFit(r, p.imageFit())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if f, fh, err := r.FormFile("image"); err == nil {
 			defer f.Close()
 			// Accept PNG/JPG/JPEG; always store normalized PNG 1600x969
@@ -1363,76 +1287,258 @@ func main() {
 				http.Error(w, "image must be .png, .jpg, or .jpeg", http.StatusBadRequest)
 				return
 			}
-			imgPath := filepath.Join(site, "img", "blog", id+".png")
 			if err := os.MkdirAll(filepath.Dir(imgPath), 0755); err != nil {
 				http.Error(w, "storage error", http.StatusInternalServerError)
 				return
 			}
-			if err := normalizeBlogImage(f, imgPath); err != nil {
+			if err := saveBlogImage(f, &p, imgPath, fit); err != nil {
+				http.Error(w, "image processing failed", http.StatusInternalServerError)
+				return
+			}
+			audit.record(r, auditPostImage, fh.Filename, id)
+		} else if fitSet && fit != p.imageFit() {
+			// Cut the kept original again
+			sources, err := regenerateBlogImage(id, imgPath, fit)
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "original image not kept; upload the image again", http.StatusConflict)
+				return
+			}
+			if err != nil {
+				log.Printf("blog edit %s image fit: %v", id, err)
 				http.Error(w, "image processing failed", http.StatusInternalServerError)
 				return
 			}
+			p.ImageSources = sources
+			p.ImageFit = &fit
+			audit.record(r, auditPostImage, fit.String(), id)
 		}
-		hPath := filepath.Join(site, "blog", id+".html")
-		b, err := os.ReadFile(hPath)
+		p.Title = title
+		if slugInput != "" {
+			p.Slug = slugInput
+		}
+		p.Annotation = annotation
+		p.ContentMode = contentMode
+		p.Body = htmlContent
+		p.Markdown = markdownSource
+		p.Categories = cats
+		p.Status = status
+		p.PublishAt = publishAt
+		p.UpdatedBy = requestUser(r)
+		saved, err := posts.put(p)
 		if err != nil {
+			log.Printf("blog edit %s: %v", id, err)
+			http.Error(w, "cannot write", http.StatusInternalServerError)
+			return
+		}
+		if _, err := revisions.record(saved, imgPath, requestUser(r), "edit"); err != nil {
+			log.Printf("warn: blog revision %s: %v", id, err)
+		}
+		audit.record(r, auditPostEdit, saved.Title, id)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Blog list (admin): every post including drafts, scheduled and archived ones
+	mux.HandleFunc("/api/blog/posts", func(w http.ResponseWriter, r *http.Request) {
+		if !requireRole(w, r, roleViewer) {
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		items := posts.listStatus("")
+		if st := r.URL.Query().Get("status"); st != "" {
+			var filtered []BlogItem
+			for _, it := range items {
+				if it.Status == st {
+					filtered = append(filtered, it)
+				}
+			}
+			items = filtered
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(items)
+	})
+
+	// Blog preview (admin): renders any post, published or not
+	mux.HandleFunc("/api/blog/preview", func(w http.ResponseWriter, r *http.Request) {
+		if !requireRole(w, r, roleViewer) {
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p, ok := posts.get(strings.TrimSpace(r.URL.Query().Get("id")))
+		if !ok {
 			http.Error(w, "not found", http.StatusNotFound)
 			return
 		}
-		s := string(b)
-		reH1 := regexp.MustCompile(`(?is)<h1[^>]*class="lte-header"[^>]*>.*?</h1>`)
-		s = reH1.ReplaceAllString(s, "<h1 class=\"lte-header\">"+htmlEscape(title)+"</h1>")
-		reContent := regexp.MustCompile(`(?is)<div class="text lte-text-page clearfix">[\s\S]*?</div>`)
-		s = reContent.ReplaceAllString(s, "<div class=\"text lte-text-page clearfix\">\n"+htmlContent+"\n</div>")
-		// Replace categories meta tags
-		reMeta := regexp.MustCompile(`(?is)<meta name="category" content="[^"]*"\s*/?>\s*`)
-		s = reMeta.ReplaceAllString(s, "")
-		if len(cats) > 0 {
-			var meta string
-			for _, c := range cats {
-				meta += "<meta name=\"category\" content=\"" + htmlEscape(c) + "\">\n"
+		b, err := renderPage(&p, true)
+		if err != nil {
+			log.Printf("blog preview: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(b)
+	})
+
+	// Blog status (admin): publish, schedule, unpublish (archive) or revert to draft
+	mux.HandleFunc("/api/blog/status", func(w http.ResponseWriter, r *http.Request) {
+		if !requireRole(w, r, roleEditor) {
+			return
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p, ok := posts.get(strings.TrimSpace(r.FormValue("id")))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		status, publishAt, err := parsePostStatus(r, p.Status)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		imgPath := filepath.Join(staticPath(), "img", "blog", p.ID+".png")
+		if err := revisions.ensureBaseline(p, imgPath); err != nil {
+			log.Printf("warn: blog revision baseline %s: %v", p.ID, err)
+		}
+		p.Status = status
+		p.PublishAt = publishAt
+		p.UpdatedBy = requestUser(r)
+		saved, err := posts.put(p)
+		if err != nil {
+			log.Printf("blog status %s: %v", p.ID, err)
+			http.Error(w, "cannot write", http.StatusInternalServerError)
+			return
+		}
+		if _, err := revisions.record(saved, imgPath, requestUser(r), "status"); err != nil {
+			log.Printf("warn: blog revision %s: %v", p.ID, err)
+		}
+		audit.record(r, auditPostStatus, saved.Status, saved.ID)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": saved.ID, "status": saved.Status, "publish_at": saved.PublishAt})
+	})
+
+	// Blog slug aliases (admin): remove a former slug so it stops redirecting
+	mux.HandleFunc("/api/blog/aliases/remove", func(w http.ResponseWriter, r *http.Request) {
+		if !requireRole(w, r, roleEditor) {
+			return
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimSpace(r.FormValue("id"))
+		slug := strings.TrimSpace(r.FormValue("slug"))
+		if err := posts.removeAlias(id, slug); err != nil {
+			if os.IsNotExist(err) {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
 			}
-			reHead := regexp.MustCompile(`(?is)</head>`)
-			s = reHead.ReplaceAllString(s, meta+"</head>")
-		}
-		// Update or add slug meta tag
-		reSlug := regexp.MustCompile(`(?is)<meta name="slug" content="[^"]*"\s*/?>\s*`)
-		s = reSlug.ReplaceAllString(s, "")
-		slugMeta := "<meta name=\"slug\" content=\"" + htmlEscape(slugInput) + "\">\n"
-		reHeadSlug := regexp.MustCompile(`(?is)</head>`)
-		s = reHeadSlug.ReplaceAllString(s, slugMeta+"</head>")
-		// Update or add annotation meta tag
-		reAnnotation := regexp.MustCompile(`(?is)<meta name="description" content="[^"]*"\s*/?>\s*`)
-		s = reAnnotation.ReplaceAllString(s, "")
-		if annotation != "" {
-			annotationMeta := "<meta name=\"description\" content=\"" + htmlEscape(annotation) + "\">\n"
-			reHeadAnnotation := regexp.MustCompile(`(?is)</head>`)
-			s = reHeadAnnotation.ReplaceAllString(s, annotationMeta+"</head>")
-		}
-		// Update or add content mode meta tag
-		reContentMode := regexp.MustCompile(`(?is)<meta name="content_mode" content="[^"]*"\s*/?>\s*`)
-		s = reContentMode.ReplaceAllString(s, "")
-		contentModeMeta := "<meta name=\"content_mode\" content=\"" + htmlEscape(contentMode) + "\">\n"
-		reHeadContentMode := regexp.MustCompile(`(?is)</head>`)
-		s = reHeadContentMode.ReplaceAllString(s, contentModeMeta+"</head>")
-		// Ensure Rybbit analytics script is present (idempotent: remove existing first, then add)
-		reRybbit := regexp.MustCompile(`(?is)<script[^>]*src="https://rybbit\.tdvorak\.dev/api/script\.js"[^>]*>\s*</script>\s*`)
-		s = reRybbit.ReplaceAllString(s, "")
-		rybbitScript := "<script src=\"https://rybbit.tdvorak.dev/api/script.js\" data-site-id=\"d40b7ffffffa\" defer></script>\n"
-		reHeadRybbit := regexp.MustCompile(`(?is)</head>`)
-		s = reHeadRybbit.ReplaceAllString(s, rybbitScript+"</head>")
-		if err := os.WriteFile(hPath, []byte(s), 0644); err != nil {
+			log.Printf("blog alias remove %s/%s: %v", id, slug, err)
 			http.Error(w, "cannot write", http.StatusInternalServerError)
 			return
 		}
+		audit.record(r, auditPostAliasRemove, slug, id)
 		w.WriteHeader(http.StatusNoContent)
 	})
 
+	// Blog revisions (admin): list saved states of a post
+	mux.HandleFunc("/api/blog/revisions", func(w http.ResponseWriter, r *http.Request) {
+		if !requireRole(w, r, roleViewer) {
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if _, ok := posts.get(id); !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		revs, err := revisions.list(id)
+		if err != nil {
+			log.Printf("blog revisions %s: %v", id, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		type revSummary struct {
+			Rev       int       `json:"rev"`
+			Time      time.Time `json:"time"`
+			Author    string    `json:"author,omitempty"`
+			Action    string    `json:"action"`
+			Title     string    `json:"title"`
+			Slug      string    `json:"slug"`
+			Status    string    `json:"status"`
+			ImageHash string    `json:"image_hash,omitempty"`
+		}
+		out := make([]revSummary, 0, len(revs))
+		for i := len(revs) - 1; i >= 0; i-- { // newest first
+			rv := revs[i]
+			out = append(out, revSummary{Rev: rv.Rev, Time: rv.Time, Author: rv.Author, Action: rv.Action, Title: rv.Post.Title, Slug: rv.Post.Slug, Status: rv.Post.Status, ImageHash: rv.ImageHash})
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(out)
+	})
+
+	// Blog revision diff (admin): ?id=&from=&to= (to defaults to the newest revision)
+	mux.HandleFunc("/api/blog/revisions/diff", func(w http.ResponseWriter, r *http.Request) {
+		if !requireRole(w, r, roleViewer) {
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		id := strings.TrimSpace(q.Get("id"))
+		from, err1 := strconv.Atoi(q.Get("from"))
+		to := 0
+		var err2 error
+		if v := q.Get("to"); v != "" {
+			to, err2 = strconv.Atoi(v)
+		}
+		if err1 != nil || err2 != nil || from <= 0 || to < 0 {
+			http.Error(w, "invalid revision", http.StatusBadRequest)
+			return
+		}
+		a, err := revisions.get(id, from)
+		if err != nil {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		b, err := revisions.get(id, to)
+		if err != nil {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		fields, body := diffRevisions(a, b)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "from": a.Rev, "to": b.Rev, "fields": fields, "body": body})
+	})
+
+	// Blog revision restore (admin): brings back content, metadata and image of
+	// a revision; the post keeps its current publication status
+	mux.HandleFunc("/api/blog/revisions/restore", revisionRestoreHandler)
+
 	// Blog delete (admin)
 	mux.HandleFunc("/api/blog/delete", func(w http.ResponseWriter, r *http.Request) {
-		okCORS(w)
-		if !checkBasicAuth(r) {
-			requireBasicAuth(w)
+		if !requireRole(w, r, roleEditor) {
 			return
 		}
 		if r.Method != http.MethodDelete {
@@ -1446,48 +1552,33 @@ func main() {
 			http.Error(w, "invalid id", http.StatusBadRequest)
 			return
 		}
-		site := staticPath()
-		blogDir := filepath.Join(site, "blog")
-
-		// If numeric ID, also find and delete the slug file
-		if regexp.MustCompile(`^\d{4}$`).MatchString(id) {
-			// Delete numeric HTML file
-			numericPath := filepath.Join(blogDir, id+".html")
-			// Find slug before deleting
-			if content, err := os.ReadFile(numericPath); err == nil {
-				slug := extractSlugFromContent(string(content))
-				if slug != "" && slug != id {
-					_ = os.Remove(filepath.Join(blogDir, slug+".html"))
-				}
-			}
-			_ = os.Remove(numericPath)
-			// Delete image
-			_ = os.Remove(filepath.Join(site, "img", "blog", id+".png"))
-		} else {
-			// It's a slug - find the numeric ID and delete both
-			entries, _ := os.ReadDir(blogDir)
-			for _, e := range entries {
-				name := e.Name()
-				if !regexp.MustCompile(`^\d{4}\.html$`).MatchString(name) {
-					continue
-				}
-				numericID := strings.TrimSuffix(name, ".html")
-				numericPath := filepath.Join(blogDir, name)
-				if content, err := os.ReadFile(numericPath); err == nil {
-					fileSlug := extractSlugFromContent(string(content))
-					if fileSlug == id {
-						// Found matching numeric file, delete both
-						_ = os.Remove(numericPath)
-						_ = os.Remove(filepath.Join(site, "img", "blog", numericID+".png"))
-						_ = os.Remove(filepath.Join(blogDir, id+".html"))
-						break
-					}
-				}
+		removed, err := posts.remove(id)
+		if err != nil {
+			if os.IsNotExist(err) {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
 			}
+			log.Printf("blog delete %s: %v", id, err)
+			http.Error(w, "cannot delete", http.StatusInternalServerError)
+			return
 		}
+		audit.record(r, auditPostDelete, removed.Title, removed.ID)
 		w.WriteHeader(http.StatusNoContent)
 	})
 
+	// Audit log and cache control (admin)
+	mux.HandleFunc("/api/admin/audit", auditHandler)
+	mux.HandleFunc("/api/admin/cache", cacheHandler)
+	mux.HandleFunc("/api/admin/cache/", cacheHandler)
+
+	// Media library (admin) and the public gallery
+	mux.HandleFunc("/api/media", mediaHandler)
+	mux.HandleFunc("/api/media/", mediaHandler)
+	mux.HandleFunc("/galerie/", galleryHandler)
+	mux.HandleFunc("/galerie.html", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/galerie/", http.StatusMovedPermanently)
+	})
+
 	// Static file server for the frontend
 	sp := staticPath()
 	log.Printf("serving static from: %s", sp)
@@ -1496,8 +1587,12 @@ func main() {
 	mux.Handle("/img/", fs)
 	mux.Handle("/css/", fs)
 	mux.Handle("/js/", fs)
-	// Protect /admin/ with Basic Auth
-	mux.Handle("/admin/", basicAuth(http.StripPrefix("/admin/", http.FileServer(http.Dir(filepath.Join(staticPath(), "admin"))))))
+	mux.Handle("/media/", fs)
+	// Admin pages need a session; the login page and session endpoints do not
+	mux.HandleFunc("/admin/login", loginHandler)
+	mux.HandleFunc("/admin/logout", logoutHandler)
+	mux.HandleFunc("/admin/session", sessionHandler)
+	mux.Handle("/admin/", adminPages(http.StripPrefix("/admin/", http.FileServer(http.Dir(filepath.Join(staticPath(), "admin"))))))
 
 	// Custom slug-based blog routing
 	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
@@ -1514,47 +1609,35 @@ func main() {
 			path = strings.TrimSuffix(path, ".html")
 		}
 
-		// Check if it's a numeric ID (legacy format)
+		// Check if it's a numeric ID (legacy format); posts with a slug URL
+		// redirect there so each post has one canonical address
 		if regexp.MustCompile(`^\d{4}$`).MatchString(path) {
+			if p, ok := posts.get(path); ok && p.Status == postPublished {
+				if link := p.item().Link; link != "/blog/"+path+".html" {
+					http.Redirect(w, r, link, http.StatusMovedPermanently)
+					return
+				}
+			}
 			// Serve numeric file directly
-			numericPath := filepath.Join(sp, "blog", path+".html")
+			numericPath := filepath.Join(blogDirFor(sp), path+".html")
 			if _, err := os.Stat(numericPath); err == nil {
 				http.ServeFile(w, r, numericPath)
 				return
 			}
 		}
 
-		// Check if it's a slug (new format)
+		// Check if it's a slug (new format); every post has a single page
+		// named after its ID
 		if regexp.MustCompile(`^[a-z0-9-]+$`).MatchString(path) {
-			slugPath := filepath.Join(sp, "blog", path+".html")
-			if _, err := os.Stat(slugPath); err == nil {
-				http.ServeFile(w, r, slugPath)
+			if id, ok := posts.lookupSlug(path); ok {
+				http.ServeFile(w, r, filepath.Join(blogDirFor(sp), id+".html"))
 				return
 			}
-
-			// If slug file doesn't exist, try to resolve slug to numeric file
-			blogDir := filepath.Join(sp, "blog")
-			entries, err := os.ReadDir(blogDir)
-			if err == nil {
-				for _, entry := range entries {
-					name := entry.Name()
-					if !regexp.MustCompile(`^\d{4}\.html$`).MatchString(name) {
-						continue
-					}
-					numericPath := filepath.Join(blogDir, name)
-
-					// Check if this numeric file has the matching slug
-					b, err := os.ReadFile(numericPath)
-					if err != nil {
-						continue
-					}
-					s := string(b)
-					re := regexp.MustCompile(`(?is)<meta name="slug" content="([^"]+)"`)
-					m := re.FindStringSubmatch(s)
-					if len(m) >= 2 && m[1] == path {
-						http.ServeFile(w, r, numericPath)
-						return
-					}
+			// Former slug: redirect to the current URL
+			if id, ok := posts.lookupAlias(path); ok {
+				if p, ok := posts.get(id); ok {
+					http.Redirect(w, r, p.item().Link, http.StatusMovedPermanently)
+					return
 				}
 			}
 		}
@@ -1563,6 +1646,17 @@ func main() {
 		http.NotFound(w, r)
 	})
 
+	// The data volume is mounted inside the site root, but it also holds post
+	// drafts, revisions and account hashes; only these files are public
+	publicData := map[string]bool{"team.xml": true, "video.json": true}
+	mux.HandleFunc("/data/", func(w http.ResponseWriter, r *http.Request) {
+		if !publicData[strings.TrimPrefix(r.URL.Path, "/data/")] {
+			http.NotFound(w, r)
+			return
+		}
+		fs.ServeHTTP(w, r)
+	})
+
 	mux.Handle("/zapasy/", fs)
 	// Fallback: serve index.html at root, otherwise delegate to static file server
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -1579,7 +1673,7 @@ func main() {
 	}
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: loadSecurityPolicy().wrap(loadCORSPolicy().wrap(mux)),
 	}
 	go func() {
 		log.Printf("server listening on :%s", port)
@@ -1594,12 +1688,6 @@ func main() {
 	_ = srv.Shutdown(ctxShut)
 }
 
-func okCORS(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-}
-
 func scheduler(ctx context.Context) {
 	// default 30m; during match window (±2h around any match today), 2m
 	for {
@@ -1609,7 +1697,7 @@ func scheduler(ctx context.Context) {
 		}
 		select {
 		case <-time.After(d):
-			if err := refresh(ctx); err != nil {
+			if _, err := refresh(ctx); err != nil {
 				log.Printf("refresh error: %v", err)
 			}
 		case <-ctx.Done():
@@ -1621,11 +1709,7 @@ func scheduler(ctx context.Context) {
 func withinMatchWindow() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	loc, err := time.LoadLocation("Europe/Prague")
-	if err != nil {
-		// Fallback to local/UTC if tzdata is missing to avoid panic
-		loc = time.Local
-	}
+	loc := pragueLocation()
 	now := time.Now().In(loc)
 	for _, comp := range c.data.ClubDetail.Competitions {
 		for _, m := range comp.Matches {
@@ -1642,6 +1726,15 @@ func withinMatchWindow() bool {
 	return false
 }
 
+func pragueLocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		// Fallback to local/UTC if tzdata is missing to avoid panic
+		return time.Local
+	}
+	return loc
+}
+
 func absDuration(d time.Duration) time.Duration {
 	if d < 0 {
 		return -d
@@ -1649,7 +1742,9 @@ func absDuration(d time.Duration) time.Duration {
 	return d
 }
 
-func refresh(ctx context.Context) error {
+// refresh fetches the club detail and table and reports whether the
+// "primary" or the "fallback" API answered
+func refresh(ctx context.Context) (string, error) {
 	client := &http.Client{Timeout: 30 * time.Second}
 	urlDetail := fmt.Sprintf("%s/club/%s/%s", baseURL, clubType, clubID)
 	urlTable := fmt.Sprintf("%s/club/%s/%s/table", baseURL, clubType, clubID)
@@ -1664,15 +1759,15 @@ func refresh(ctx context.Context) error {
 		urlDetail = fmt.Sprintf("%s/club/%s/%s?slug=%s", fallbackBaseURL, clubType, fallbackClubID, fallbackSlug)
 		urlTable = fmt.Sprintf("%s/club/%s/%s/table?slug=%s", fallbackBaseURL, clubType, fallbackClubID, fallbackSlug)
 		if err := getJSON(ctx, client, urlDetail, &detail); err != nil {
-			return fmt.Errorf("fallback detail: %w", err)
+			return "", fmt.Errorf("fallback detail: %w", err)
 		}
 		if err := getJSON(ctx, client, urlTable, &table); err != nil {
-			return fmt.Errorf("fallback table: %w", err)
+			return "", fmt.Errorf("fallback table: %w", err)
 		}
 		activeClubID = fallbackClubID
 	} else {
 		if err := getJSON(ctx, client, urlTable, &table); err != nil {
-			return fmt.Errorf("table: %w", err)
+			return "", fmt.Errorf("table: %w", err)
 		}
 		activeClubID = clubID
 	}
@@ -1715,8 +1810,11 @@ func refresh(ctx context.Context) error {
 	if err := writeDiskJSON(c.data); err != nil {
 		log.Printf("warn: write disk json: %v", err)
 	}
-	log.Printf("refreshed data: comps=%d source=%s", len(detail.Competitions), map[bool]string{true: "fallback", false: "primary"}[activeClubID == fallbackClubID])
-	return nil
+	source := map[bool]string{true: "fallback", false: "primary"}[activeClubID == fallbackClubID]
+	log.Printf("refreshed data: comps=%d source=%s", len(detail.Competitions), source)
+	// Match and table cards in posts show the new data
+	refreshShortcodePages()
+	return source, nil
 }
 
 func getJSON(ctx context.Context, client *http.Client, url string, out any) error {
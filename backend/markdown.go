@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// ---------------- Markdown posts ----------------
+// Posts in content mode "markdown" keep their source in postRecord.Markdown;
+// Body holds the HTML rendered from it when it was saved, for listings, search
+// and feeds, and pages are rendered from the source again. Besides CommonMark
+// the renderer knows GitHub tables, strikethrough and autolinks, plus:
+//
+//	![alt](/img/foto.jpg "Caption")   an image alone in a paragraph with a
+//	                                  title becomes a <figure> with <figcaption>
+//	[youtube dQw4w9WgXcQ]             a line holding only this embeds the video
+//	[youtube id="dQw4w9WgXcQ"]        (a full YouTube URL works as the id too)
+//
+// Raw HTML is passed through and, like every post body, sanitized afterwards.
+
+// Content modes of a post
+const (
+	contentVisual   = "visual"
+	contentHTML     = "html"
+	contentMarkdown = "markdown"
+)
+
+// markdownVersion is part of the layout hash; bump it when the rendering of
+// Markdown changes so stored pages are rendered again
+const markdownVersion = "1"
+
+var reYouTubeShortcode = regexp.MustCompile(`^\[youtube\s+(?:id=)?"?([^"\s\]]+)"?\s*\]$`)
+
+var reYouTubeID = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.Table, extension.Strikethrough, extension.Linkify),
+	goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(markdownBlocks{}, 100))),
+	goldmark.WithRendererOptions(
+		gmhtml.WithUnsafe(),
+		renderer.WithNodeRenderers(util.Prioritized(markdownBlockRenderer{}, 100)),
+	),
+)
+
+// renderMarkdown converts a Markdown post body to sanitized HTML
+func renderMarkdown(src string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(src), &buf); err != nil {
+		return "", fmt.Errorf("render markdown: %w", err)
+	}
+	return sanitizeHTML(buf.String()), nil
+}
+
+// postContent turns submitted editor content into the stored body and, for
+// Markdown posts, the source it was rendered from
+func postContent(mode, content string) (body, source string, err error) {
+	switch mode {
+	case contentVisual, contentHTML:
+		return strings.TrimSpace(sanitizeHTML(content)), "", nil
+	case contentMarkdown:
+		source = strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+		body, err = renderMarkdown(source)
+		return strings.TrimSpace(body), source, err
+	}
+	return "", "", fmt.Errorf("invalid content mode %q", mode)
+}
+
+// bodyHTML returns the HTML shown on the page of p
+func (p *postRecord) bodyHTML() string {
+	if p.ContentMode == contentMarkdown && p.Markdown != "" {
+		body, err := renderMarkdown(p.Markdown)
+		if err == nil {
+			return body
+		}
+	}
+	return sanitizeHTML(p.Body)
+}
+
+// youTubeID extracts the video id of a shortcode argument, an id or a URL
+func youTubeID(arg string) string {
+	if reYouTubeID.MatchString(arg) {
+		return arg
+	}
+	if m := reYouTubeURL.FindStringSubmatch(arg); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+var reYouTubeURL = regexp.MustCompile(`^https?://(?:www\.|m\.)?(?:youtube\.com/(?:watch\?v=|embed/|shorts/)|youtu\.be/)([A-Za-z0-9_-]{11})`)
+
+var (
+	kindFigure       = ast.NewNodeKind("Figure")
+	kindYouTubeEmbed = ast.NewNodeKind("YouTubeEmbed")
+)
+
+// figureNode wraps an image with its caption
+type figureNode struct {
+	ast.BaseBlock
+	caption string
+}
+
+func (n *figureNode) Kind() ast.NodeKind { return kindFigure }
+
+func (n *figureNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Caption": n.caption}, nil)
+}
+
+// youTubeNode is an embedded YouTube video
+type youTubeNode struct {
+	ast.BaseBlock
+	id string
+}
+
+func (n *youTubeNode) Kind() ast.NodeKind { return kindYouTubeEmbed }
+
+func (n *youTubeNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"ID": n.id}, nil)
+}
+
+// markdownBlocks replaces paragraphs holding only a captioned image or a
+// YouTube shortcode by figure and video nodes
+type markdownBlocks struct{}
+
+func (markdownBlocks) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var paras []*ast.Paragraph
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if p, ok := n.(*ast.Paragraph); ok && entering {
+			paras = append(paras, p)
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, p := range paras {
+		if p.Lines().Len() == 1 {
+			line := p.Lines().At(0)
+			m := reYouTubeShortcode.FindSubmatch(bytes.TrimSpace(line.Value(source)))
+			if m != nil {
+				if id := youTubeID(string(m[1])); id != "" {
+					p.Parent().ReplaceChild(p.Parent(), p, &youTubeNode{id: id})
+					continue
+				}
+			}
+		}
+		img, ok := p.FirstChild().(*ast.Image)
+		if !ok || img.NextSibling() != nil || len(img.Title) == 0 {
+			continue
+		}
+		fig := &figureNode{caption: string(img.Title)}
+		p.Parent().ReplaceChild(p.Parent(), p, fig)
+		fig.AppendChild(fig, img)
+	}
+}
+
+// markdownBlockRenderer writes figure and video nodes
+type markdownBlockRenderer struct{}
+
+func (markdownBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindFigure, renderFigure)
+	reg.Register(kindYouTubeEmbed, renderYouTube)
+}
+
+func renderFigure(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString("<figure>")
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString("<figcaption>")
+	_, _ = w.Write(util.EscapeHTML([]byte(n.(*figureNode).caption)))
+	_, _ = w.WriteString("</figcaption></figure>\n")
+	return ast.WalkContinue, nil
+}
+
+func renderYouTube(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		fmt.Fprintf(w, `<div class="video-embed"><iframe src="https://www.youtube-nocookie.com/embed/%s" width="560" height="315" title="YouTube video" `+
+			`allow="accelerometer; encrypted-media; gyroscope; picture-in-picture" allowfullscreen></iframe></div>`+"\n", n.(*youTubeNode).id)
+	}
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	src := "## Sestava\n\n" +
+		"| Hráč | Góly |\n|:-----|-----:|\n| Novák | 2 |\n\n" +
+		"![Oslava](/img/oslava.jpg \"Radost po druhém gólu\")\n\n" +
+		"[youtube dQw4w9WgXcQ]\n\n" +
+		"[youtube id=\"https://youtu.be/dQw4w9WgXcQ\"]\n\n" +
+		"    [youtube dQw4w9WgXcQ]\n\n" +
+		"Text s ~~chybou~~ a <script>alert(1)</script> odkazem https://fotbal.cz\n"
+	got, err := renderMarkdown(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<h2>Sestava</h2>`,
+		`<th style="text-align: left">Hráč</th>`,
+		`<td style="text-align: right">2</td>`,
+		`<figure><img src="/img/oslava.jpg" alt="Oslava" title="Radost po druhém gólu"><figcaption>Radost po druhém gólu</figcaption></figure>`,
+		`<iframe src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"`,
+		`<pre><code>[youtube dQw4w9WgXcQ]`,
+		`<del>chybou</del>`,
+		`<a href="https://fotbal.cz">https://fotbal.cz</a>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "<iframe"); n != 2 {
+		t.Errorf("expected 2 embeds, got %d", n)
+	}
+	if strings.Contains(got, "<script") {
+		t.Errorf("script survived: %s", got)
+	}
+}
+
+func TestPostContent(t *testing.T) {
+	body, source, err := postContent(contentMarkdown, "# Výhra\r\n\r\n**3:1**\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != "# Výhra\n\n**3:1**" || !strings.Contains(body, "<strong>3:1</strong>") {
+		t.Errorf("unexpected content %q / %q", body, source)
+	}
+	if body, source, _ = postContent(contentHTML, `<p onclick="x()">a</p>`); body != "<p>a</p>" || source != "" {
+		t.Errorf("unexpected html content %q / %q", body, source)
+	}
+	if _, _, err := postContent("word", "x"); err == nil {
+		t.Error("unknown content mode accepted")
+	}
+}
+
+// TestMarkdownPostPage verifies the page is rendered from the Markdown source
+func TestMarkdownPostPage(t *testing.T) {
+	blogDir := filepath.Join(t.TempDir(), "blog")
+	os.MkdirAll(blogDir, 0755)
+	var st postStore
+	if err := st.open(blogDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	body, source, _ := postContent(contentMarkdown, "Úvod\n\n[youtube dQw4w9WgXcQ]")
+	if _, err := st.put(postRecord{ID: "0001", Slug: "video", Title: "Video", ContentMode: contentMarkdown, Body: body, Markdown: source, Status: postPublished}); err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(filepath.Join(blogDir, "0001.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "youtube-nocookie.com/embed/dQw4w9WgXcQ") {
+		t.Errorf("embed missing from page")
+	}
+	a := postRevision{Post: postRecord{ContentMode: contentMarkdown, Markdown: "Úvod\n\nKonec"}}
+	b := postRevision{Post: postRecord{ContentMode: contentMarkdown, Markdown: "Úvod\n\nNový konec"}}
+	if _, lines := diffRevisions(a, b); len(lines) != 3 || lines[1].Text != "Konec" {
+		t.Errorf("unexpected markdown diff %+v", lines)
+	}
+}
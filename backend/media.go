@@ -0,0 +1,782 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	_ "image/gif"
+	"image/jpeg"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "golang.org/x/image/webp"
+)
+
+// ---------------- Media library ----------------
+// Images and files uploaded through /api/media are kept as assets, listed in
+// media.json on the data volume and served from <site>/media/. Images are
+// turned upright and stored without their metadata in the variant widths of
+// the post images (<id>-<width>.jpg/.webp, at most mediaMaxSize) plus a
+// cropped thumbnail (<id>-thumb.jpg); other files are stored as uploaded
+// (<id>-<name>.<ext>). Assets can be grouped into albums.
+//
+// Post bodies refer to assets by ID with the [media] and [gallery]
+// shortcodes, and /galerie/ renders the albums. IDs are never reused, so a
+// shortcode of a deleted asset renders nothing instead of another picture.
+
+//go:embed templates/gallery.html
+var galleryLayoutSrc string
+
+var galleryLayout = template.Must(template.New("gallery").Parse(galleryLayoutSrc))
+
+// Asset kinds
+const (
+	mediaImage = "image"
+	mediaFile  = "file"
+)
+
+const (
+	// mediaMaxSize bounds the longer side of stored images
+	mediaMaxSize = 1600
+	mediaThumbW  = 600
+	mediaThumbH  = 400
+	// mediaMaxUpload is the largest accepted file
+	mediaMaxUpload = 25 << 20
+	// mediaMaxRequest bounds one upload request with several files
+	mediaMaxRequest = 200 << 20
+)
+
+// mediaImageExts are decoded and stored as images
+var mediaImageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
+
+// mediaFileTypes are the other accepted files; nothing a browser would run
+// (HTML, SVG, scripts) is on the list
+var mediaFileTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".txt":  "text/plain; charset=utf-8",
+	".csv":  "text/csv; charset=utf-8",
+}
+
+// mediaAsset is one uploaded image or file
+type mediaAsset struct {
+	ID     string   `json:"id"`
+	Kind   string   `json:"kind"`            // image or file
+	Name   string   `json:"name"`            // uploaded file name
+	Title  string   `json:"title,omitempty"` // caption and alt text
+	Albums []string `json:"albums,omitempty"`
+	Type   string   `json:"type"` // MIME type of URL
+	Size   int64    `json:"size"` // bytes uploaded
+	// URL is the file, or the largest JPEG of an image
+	URL        string        `json:"url"`
+	Thumb      string        `json:"thumb,omitempty"`
+	Width      int           `json:"width,omitempty"`
+	Height     int           `json:"height,omitempty"`
+	Sources    []imageSource `json:"sources,omitempty"`
+	Meta       *imageMeta    `json:"meta,omitempty"`
+	UploadedBy string        `json:"uploaded_by,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// label is the title, or the file name when there is none
+func (a *mediaAsset) label() string {
+	if a.Title != "" {
+		return a.Title
+	}
+	return a.Name
+}
+
+// mediaAlbum groups assets for the gallery
+type mediaAlbum struct {
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Cover       string    `json:"cover,omitempty"` // asset ID; default the first image
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// mediaAlbumInfo is an album with what lists of albums show
+type mediaAlbumInfo struct {
+	mediaAlbum
+	Count int    `json:"count"`
+	Thumb string `json:"thumb,omitempty"`
+}
+
+type mediaStore struct {
+	mu     sync.RWMutex
+	dir    string // public files
+	path   string // media.json
+	assets map[string]*mediaAsset
+	albums map[string]*mediaAlbum
+	lastID int
+}
+
+// mediaState is the persisted form of the store
+type mediaState struct {
+	LastID int           `json:"last_id"`
+	Assets []*mediaAsset `json:"assets"`
+	Albums []*mediaAlbum `json:"albums"`
+}
+
+var media mediaStore
+
+func mediaPath() string {
+	if p := os.Getenv("MEDIA_PATH"); p != "" {
+		return p
+	}
+	// Default: next to club.json on the data volume
+	return filepath.Join(filepath.Dir(dataPath()), "media.json")
+}
+
+// mediaDirFor returns the directory holding the media files of a site root
+func mediaDirFor(siteRoot string) string {
+	return filepath.Join(siteRoot, "media")
+}
+
+// open loads the persisted library (empty if the file does not exist)
+func (s *mediaStore) open(dir, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dir, s.path = dir, path
+	s.assets = make(map[string]*mediaAsset)
+	s.albums = make(map[string]*mediaAlbum)
+	s.lastID = 0
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st mediaState
+	if err := json.Unmarshal(b, &st); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	s.lastID = st.LastID
+	for _, a := range st.Assets {
+		s.assets[a.ID] = a
+	}
+	for _, al := range st.Albums {
+		s.albums[al.Slug] = al
+	}
+	return nil
+}
+
+func (s *mediaStore) saveLocked() error {
+	st := mediaState{LastID: s.lastID, Assets: s.sortedLocked(), Albums: make([]*mediaAlbum, 0, len(s.albums))}
+	for _, al := range s.albums {
+		st.Albums = append(st.Albums, al)
+	}
+	sort.Slice(st.Albums, func(i, j int) bool { return st.Albums[i].Slug < st.Albums[j].Slug })
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, b)
+}
+
+// sortedLocked returns the assets in upload order
+func (s *mediaStore) sortedLocked() []*mediaAsset {
+	out := make([]*mediaAsset, 0, len(s.assets))
+	for _, a := range s.assets {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, _ := strconv.Atoi(out[i].ID)
+		b, _ := strconv.Atoi(out[j].ID)
+		return a < b
+	})
+	return out
+}
+
+// checkAlbumsLocked returns an error for slugs that name no album
+func (s *mediaStore) checkAlbumsLocked(slugs []string) error {
+	for _, slug := range slugs {
+		if s.albums[slug] == nil {
+			return fmt.Errorf("unknown album %q", slug)
+		}
+	}
+	return nil
+}
+
+// add stores an upload as a new asset in the given albums
+func (s *mediaStore) add(name string, data []byte, title string, albums []string, user string) (mediaAsset, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if !mediaImageExts[ext] && mediaFileTypes[ext] == "" {
+		return mediaAsset{}, fmt.Errorf("%s: unsupported file type", name)
+	}
+	if len(data) > mediaMaxUpload {
+		return mediaAsset{}, fmt.Errorf("%s: larger than %d MB", name, mediaMaxUpload>>20)
+	}
+	// Reserve the ID; processing runs without the lock
+	s.mu.Lock()
+	if err := s.checkAlbumsLocked(albums); err != nil {
+		s.mu.Unlock()
+		return mediaAsset{}, err
+	}
+	s.lastID++
+	id := strconv.Itoa(s.lastID)
+	dir := s.dir
+	s.mu.Unlock()
+
+	a := mediaAsset{
+		ID: id, Name: filepath.Base(name), Title: title, Albums: albums,
+		Size: int64(len(data)), UploadedBy: user, CreatedAt: time.Now().UTC(),
+	}
+	if mediaImageExts[ext] {
+		if err := storeMediaImage(&a, data, dir); err != nil {
+			removeMediaFiles(dir, id)
+			return mediaAsset{}, fmt.Errorf("%s: %w", name, err)
+		}
+	} else {
+		a.Kind, a.Type = mediaFile, mediaFileTypes[ext]
+		base := generateSlug(strings.TrimSuffix(a.Name, filepath.Ext(a.Name)))
+		if base == "" {
+			base = "soubor"
+		}
+		file := id + "-" + base + ext
+		if err := writeFileAtomic(filepath.Join(dir, file), data); err != nil {
+			return mediaAsset{}, err
+		}
+		a.URL = "/media/" + file
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// An album may have been deleted meanwhile
+	if err := s.checkAlbumsLocked(albums); err != nil {
+		removeMediaFiles(dir, id)
+		return mediaAsset{}, err
+	}
+	s.assets[id] = &a
+	return a, s.saveLocked()
+}
+
+// storeMediaImage writes the variants and thumbnail of an uploaded image
+func storeMediaImage(a *mediaAsset, data []byte, dir string) error {
+	img, format, err := decodeImage(data)
+	if err != nil {
+		return err
+	}
+	exif := readEXIF(data)
+	img = orientImage(img, exif.Orientation)
+	b := img.Bounds()
+	w, h := fitWithin(b.Dx(), b.Dy(), mediaMaxSize, mediaMaxSize, 1)
+	if w != b.Dx() || h != b.Dy() {
+		img = resample(img, w, h, imageResampleFilter())
+	}
+	sources, err := writeSizedVariants(img, dir, a.ID, "/media/", format != "jpeg")
+	if err != nil {
+		return err
+	}
+	fx, fy := detailFocus(img, mediaThumbW, mediaThumbH)
+	var tb bytes.Buffer
+	if err := jpeg.Encode(&tb, coverCrop(img, fx, fy, mediaThumbW, mediaThumbH), &jpeg.Options{Quality: imageVariantJPEGQuality}); err != nil {
+		return fmt.Errorf("encode thumbnail: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, a.ID+"-thumb.jpg"), tb.Bytes()); err != nil {
+		return err
+	}
+	a.Kind, a.Type = mediaImage, "image/jpeg"
+	a.Width, a.Height = w, h
+	a.Sources = sources
+	a.Thumb = "/media/" + a.ID + "-thumb.jpg"
+	for _, src := range sources {
+		if src.Type == "image/jpeg" {
+			a.URL = src.URL
+		}
+	}
+	a.Meta = exif.meta()
+	return nil
+}
+
+// removeMediaFiles deletes every stored file of asset id
+func removeMediaFiles(dir, id string) {
+	paths, _ := filepath.Glob(filepath.Join(dir, id+"-*"))
+	for _, p := range paths {
+		_ = os.Remove(p)
+	}
+}
+
+// get returns an asset by ID
+func (s *mediaStore) get(id string) (mediaAsset, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a := s.assets[id]
+	if a == nil {
+		return mediaAsset{}, false
+	}
+	return *a, true
+}
+
+// mediaQuery filters list; empty fields match everything
+type mediaQuery struct {
+	Text   string // in name, title or album titles
+	Album  string
+	Kind   string
+	Offset int
+	Limit  int // 0: all
+}
+
+// list returns the matching assets, newest first, and how many match
+func (s *mediaStore) list(q mediaQuery) ([]mediaAsset, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	words := strings.Fields(foldCzech(q.Text))
+	all := s.sortedLocked()
+	var out []mediaAsset
+	for i := len(all) - 1; i >= 0; i-- {
+		a := all[i]
+		if q.Kind != "" && a.Kind != q.Kind {
+			continue
+		}
+		if q.Album != "" && !containsString(a.Albums, q.Album) {
+			continue
+		}
+		if len(words) > 0 {
+			text := a.Name + " " + a.Title
+			for _, slug := range a.Albums {
+				if al := s.albums[slug]; al != nil {
+					text += " " + al.Title
+				}
+			}
+			text = foldCzech(text)
+			match := true
+			for _, w := range words {
+				if !strings.Contains(text, w) {
+					match = false
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+		}
+		out = append(out, *a)
+	}
+	total := len(out)
+	out = out[min(q.Offset, total):]
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, total
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// update sets the title and albums of an asset; nil leaves a field as is
+func (s *mediaStore) update(id string, title *string, albums []string) (mediaAsset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.assets[id]
+	if a == nil {
+		return mediaAsset{}, os.ErrNotExist
+	}
+	if albums != nil {
+		if err := s.checkAlbumsLocked(albums); err != nil {
+			return mediaAsset{}, err
+		}
+		a.Albums = albums
+		if len(albums) == 0 {
+			a.Albums = nil
+		}
+	}
+	if title != nil {
+		a.Title = *title
+	}
+	return *a, s.saveLocked()
+}
+
+// remove deletes an asset and its files
+func (s *mediaStore) remove(id string) (mediaAsset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.assets[id]
+	if a == nil {
+		return mediaAsset{}, os.ErrNotExist
+	}
+	removeMediaFiles(s.dir, id)
+	delete(s.assets, id)
+	for _, al := range s.albums {
+		if al.Cover == id {
+			al.Cover = ""
+		}
+	}
+	return *a, s.saveLocked()
+}
+
+// albumList returns the albums with their size and cover, newest first
+func (s *mediaStore) albumList() []mediaAlbumInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]mediaAlbumInfo, 0, len(s.albums))
+	for _, al := range s.albums {
+		out = append(out, s.albumInfoLocked(al))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].Slug < out[j].Slug
+	})
+	return out
+}
+
+func (s *mediaStore) albumInfoLocked(al *mediaAlbum) mediaAlbumInfo {
+	info := mediaAlbumInfo{mediaAlbum: *al}
+	if c := s.assets[al.Cover]; c != nil {
+		info.Thumb = c.Thumb
+	}
+	for _, a := range s.sortedLocked() {
+		if !containsString(a.Albums, al.Slug) {
+			continue
+		}
+		info.Count++
+		if info.Thumb == "" {
+			info.Thumb = a.Thumb
+		}
+	}
+	return info
+}
+
+// album returns an album and its assets in upload order
+func (s *mediaStore) album(slug string) (mediaAlbumInfo, []mediaAsset, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	al := s.albums[slug]
+	if al == nil {
+		return mediaAlbumInfo{}, nil, false
+	}
+	var assets []mediaAsset
+	for _, a := range s.sortedLocked() {
+		if containsString(a.Albums, slug) {
+			assets = append(assets, *a)
+		}
+	}
+	return s.albumInfoLocked(al), assets, true
+}
+
+// saveAlbum creates an album (empty slug: derived from the title) or updates
+// the title, description and cover of an existing one
+func (s *mediaStore) saveAlbum(al mediaAlbum) (mediaAlbum, error) {
+	al.Title = strings.TrimSpace(al.Title)
+	if al.Title == "" {
+		return mediaAlbum{}, errors.New("missing album title")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if al.Cover != "" && s.assets[al.Cover] == nil {
+		return mediaAlbum{}, fmt.Errorf("unknown cover %q", al.Cover)
+	}
+	if al.Slug == "" {
+		base := generateSlug(al.Title)
+		if base == "" {
+			base = "album"
+		}
+		al.Slug = base
+		for n := 2; s.albums[al.Slug] != nil; n++ {
+			al.Slug = base + "-" + strconv.Itoa(n)
+		}
+		al.CreatedAt = time.Now().UTC()
+	} else {
+		cur := s.albums[al.Slug]
+		if cur == nil {
+			return mediaAlbum{}, os.ErrNotExist
+		}
+		al.CreatedAt = cur.CreatedAt
+	}
+	s.albums[al.Slug] = &al
+	return al, s.saveLocked()
+}
+
+// removeAlbum deletes an album; its assets stay in the library
+func (s *mediaStore) removeAlbum(slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.albums[slug] == nil {
+		return os.ErrNotExist
+	}
+	delete(s.albums, slug)
+	for _, a := range s.assets {
+		for i, v := range a.Albums {
+			if v == slug {
+				a.Albums = append(a.Albums[:i:i], a.Albums[i+1:]...)
+				break
+			}
+		}
+		if len(a.Albums) == 0 {
+			a.Albums = nil
+		}
+	}
+	return s.saveLocked()
+}
+
+// splitList splits a comma-separated form value
+func splitList(v string) []string {
+	var out []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// mediaHandler serves the media library API:
+//
+//	GET  /api/media                  list (q, album, kind, limit, offset)
+//	GET  /api/media/get?id=          one asset
+//	POST /api/media/upload           files (several), title, albums
+//	POST /api/media/edit             id, title, albums
+//	POST /api/media/delete           id
+//	GET  /api/media/albums           albums with size and cover
+//	POST /api/media/albums/save      slug (empty: new), title, description, cover
+//	POST /api/media/albums/delete    slug
+func mediaHandler(w http.ResponseWriter, r *http.Request) {
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/media"), "/")
+	role := roleEditor
+	if r.Method == http.MethodGet {
+		role = roleViewer
+	}
+	if !requireRole(w, r, role) {
+		return
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON := func(v any) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		v := r.URL.Query()
+		q := mediaQuery{Text: v.Get("q"), Album: v.Get("album"), Kind: v.Get("kind"), Limit: 100}
+		var err error
+		if s := v.Get("limit"); s != "" {
+			if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		if s := v.Get("offset"); s != "" {
+			if q.Offset, err = strconv.Atoi(s); err != nil || q.Offset < 0 {
+				http.Error(w, "invalid offset", http.StatusBadRequest)
+				return
+			}
+		}
+		items, total := media.list(q)
+		if items == nil {
+			items = []mediaAsset{}
+		}
+		writeJSON(map[string]any{"items": items, "total": total})
+
+	case action == "get" && r.Method == http.MethodGet:
+		a, ok := media.get(r.URL.Query().Get("id"))
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(a)
+
+	case action == "upload" && r.Method == http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, mediaMaxRequest)
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			http.Error(w, "invalid form or upload too large", http.StatusBadRequest)
+			return
+		}
+		files := r.MultipartForm.File["files"]
+		if len(files) == 0 {
+			http.Error(w, "missing files", http.StatusBadRequest)
+			return
+		}
+		title := strings.TrimSpace(r.FormValue("title"))
+		albums := splitList(r.FormValue("albums"))
+		var added []mediaAsset
+		var failed []string
+		for _, fh := range files {
+			f, err := fh.Open()
+			if err != nil {
+				failed = append(failed, fh.Filename+": "+err.Error())
+				continue
+			}
+			var buf bytes.Buffer
+			_, err = buf.ReadFrom(f)
+			f.Close()
+			if err != nil {
+				failed = append(failed, fh.Filename+": "+err.Error())
+				continue
+			}
+			a, err := media.add(fh.Filename, buf.Bytes(), title, albums, requestUser(r))
+			if err != nil {
+				log.Printf("media upload: %v", err)
+				failed = append(failed, err.Error())
+				continue
+			}
+			added = append(added, a)
+			audit.record(r, auditMediaUpload, a.Name, a.ID)
+		}
+		if len(added) == 0 {
+			http.Error(w, strings.Join(failed, "\n"), http.StatusBadRequest)
+			return
+		}
+		if len(albums) > 0 {
+			refreshShortcodePages()
+		}
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(map[string]any{"items": added, "errors": failed})
+
+	case action == "edit" && r.Method == http.MethodPost:
+		id := r.FormValue("id")
+		var title *string
+		if _, ok := r.Form["title"]; ok {
+			t := strings.TrimSpace(r.FormValue("title"))
+			title = &t
+		}
+		var albums []string
+		if _, ok := r.Form["albums"]; ok {
+			albums = append([]string{}, splitList(r.FormValue("albums"))...)
+		}
+		a, err := media.update(id, title, albums)
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit.record(r, auditMediaEdit, a.label(), id)
+		refreshShortcodePages()
+		writeJSON(a)
+
+	case action == "delete" && r.Method == http.MethodPost:
+		a, err := media.remove(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		audit.record(r, auditMediaDelete, a.Name, a.ID)
+		refreshShortcodePages()
+		w.WriteHeader(http.StatusNoContent)
+
+	case action == "albums" && r.Method == http.MethodGet:
+		writeJSON(map[string]any{"items": media.albumList()})
+
+	case action == "albums/save" && r.Method == http.MethodPost:
+		al, err := media.saveAlbum(mediaAlbum{
+			Slug:        strings.TrimSpace(r.FormValue("slug")),
+			Title:       r.FormValue("title"),
+			Description: strings.TrimSpace(r.FormValue("description")),
+			Cover:       strings.TrimSpace(r.FormValue("cover")),
+		})
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit.record(r, auditMediaAlbum, al.Title, al.Slug)
+		refreshShortcodePages()
+		writeJSON(al)
+
+	case action == "albums/delete" && r.Method == http.MethodPost:
+		slug := r.FormValue("slug")
+		if err := media.removeAlbum(slug); err != nil {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		audit.record(r, auditMediaAlbumDelete, "", slug)
+		refreshShortcodePages()
+		w.WriteHeader(http.StatusNoContent)
+
+	case action == "" || action == "get" || action == "upload" || action == "edit" || action == "delete" ||
+		action == "albums" || action == "albums/save" || action == "albums/delete":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// galleryPage is the data of the gallery layout
+type galleryPage struct {
+	Title       string
+	Description string
+	URL         string // absolute canonical URL
+	ImageURL    string
+	Album       bool // one album rather than the list
+	Albums      []mediaAlbumInfo
+	Assets      []mediaAsset
+}
+
+// galleryHandler renders /galerie/ (the albums) and /galerie/<slug> (the
+// images of one album)
+func galleryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/galerie/"), "/")
+	base := siteBaseURL()
+	page := galleryPage{Title: "Fotogalerie", URL: base + "/galerie/"}
+	if slug == "" {
+		for _, al := range media.albumList() {
+			if al.Count > 0 {
+				page.Albums = append(page.Albums, al)
+			}
+		}
+		if len(page.Albums) > 0 && page.Albums[0].Thumb != "" {
+			page.ImageURL = base + page.Albums[0].Thumb
+		}
+	} else {
+		info, assets, ok := media.album(slug)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		page.Album = true
+		page.Title, page.Description = info.Title, info.Description
+		page.URL = base + "/galerie/" + info.Slug
+		if info.Thumb != "" {
+			page.ImageURL = base + info.Thumb
+		}
+		for _, a := range assets {
+			if a.Kind == mediaImage {
+				page.Assets = append(page.Assets, a)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := galleryLayout.Execute(&buf, page); err != nil {
+		log.Printf("gallery %q: %v", slug, err)
+		http.Error(w, "render failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
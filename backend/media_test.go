@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useMediaLibrary points the media store at an empty library in a temp dir
+func useMediaLibrary(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := media.open(filepath.Join(dir, "media"), filepath.Join(dir, "media.json")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { media.open(filepath.Join(dir, "none"), filepath.Join(dir, "none.json")) })
+	return dir
+}
+
+func TestMediaLibrary(t *testing.T) {
+	dir := useMediaLibrary(t)
+	mediaDir := filepath.Join(dir, "media")
+
+	al, err := media.saveAlbum(mediaAlbum{Title: "Zimní turnaj 2026"})
+	if err != nil || al.Slug != "zimni-turnaj-2026" {
+		t.Fatalf("album: %+v, %v", al, err)
+	}
+	if again, _ := media.saveAlbum(mediaAlbum{Title: "Zimní turnaj 2026"}); again.Slug != "zimni-turnaj-2026-2" {
+		t.Errorf("second album slug %q", again.Slug)
+	}
+
+	// Large images are brought down to mediaMaxSize
+	wide, err := media.add("hala.png", encodePNG(t, halves(2000, 1000)).Bytes(), "Hala", []string{al.Slug}, "trener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wide.ID != "1" || wide.Kind != mediaImage || wide.Width != 1600 || wide.Height != 800 || len(wide.Sources) < 3 {
+		t.Errorf("image asset: %+v", wide)
+	}
+	f, err := os.Open(filepath.Join(mediaDir, "1-thumb.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(f)
+	f.Close()
+	if err != nil || cfg.Width != mediaThumbW || cfg.Height != mediaThumbH {
+		t.Errorf("thumbnail: %+v, %v", cfg, err)
+	}
+
+	// Phone photos are turned upright and lose their metadata
+	photo := sidewaysJPEG(t, 600, 300, jpegSegment(0xe1, "Exif\x00\x00"+string(testEXIF(binary.BigEndian, 6))))
+	up, err := media.add("IMG_0001.JPG", photo, "", []string{al.Slug}, "trener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up.Width != 300 || up.Height != 600 || up.Meta == nil || up.Meta.Camera != "Google Pixel 7" {
+		t.Errorf("phone photo: %+v", up)
+	}
+	entries, _ := os.ReadDir(mediaDir)
+	for _, e := range entries {
+		if b, _ := os.ReadFile(filepath.Join(mediaDir, e.Name())); bytes.Contains(b, []byte("Pixel")) {
+			t.Errorf("%s keeps metadata", e.Name())
+		}
+	}
+
+	doc, err := media.add("Rozpis zápasů.pdf", []byte("%PDF-1.4"), "", nil, "trener")
+	if err != nil || doc.Kind != mediaFile || doc.URL != "/media/3-rozpis-zapasu.pdf" || doc.Type != "application/pdf" {
+		t.Errorf("file asset: %+v, %v", doc, err)
+	}
+	for _, name := range []string{"stranka.html", "logo.svg"} {
+		if _, err := media.add(name, []byte("<svg/>"), "", nil, "trener"); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+	if _, err := media.add("x.png", encodePNG(t, halves(10, 10)).Bytes(), "", []string{"nic"}, "trener"); err == nil {
+		t.Error("unknown album accepted")
+	}
+
+	// Search covers names, titles and album titles, without diacritics
+	if got, total := media.list(mediaQuery{Text: "zimni"}); total != 2 || got[0].ID != "2" {
+		t.Errorf("search by album: %d %+v", total, got)
+	}
+	if got, _ := media.list(mediaQuery{Text: "ROZPIS"}); len(got) != 1 || got[0].ID != "3" {
+		t.Errorf("search by name: %+v", got)
+	}
+	if _, total := media.list(mediaQuery{Kind: mediaFile}); total != 1 {
+		t.Errorf("files: %d", total)
+	}
+	if got, total := media.list(mediaQuery{Limit: 1, Offset: 1}); total != 3 || len(got) != 1 || got[0].ID != "2" {
+		t.Errorf("page: %d %+v", total, got)
+	}
+
+	// Deleted IDs are not reused, also after a restart
+	if _, err := media.remove("3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, "3-rozpis-zapasu.pdf")); !os.IsNotExist(err) {
+		t.Error("file of deleted asset kept")
+	}
+	if err := media.open(mediaDir, filepath.Join(dir, "media.json")); err != nil {
+		t.Fatal(err)
+	}
+	if next, err := media.add("pozvanka.txt", []byte("Zveme vás"), "", nil, ""); err != nil || next.ID != "4" {
+		t.Errorf("after restart: %+v, %v", next, err)
+	}
+	info, assets, ok := media.album(al.Slug)
+	if !ok || info.Count != 2 || info.Thumb != "/media/1-thumb.jpg" || len(assets) != 2 {
+		t.Errorf("album after restart: %+v %d", info, len(assets))
+	}
+
+	if err := media.removeAlbum(al.Slug); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := media.get("1"); len(a.Albums) != 0 {
+		t.Errorf("asset still in deleted album: %+v", a.Albums)
+	}
+}
+
+func TestMediaShortcodes(t *testing.T) {
+	useMediaLibrary(t)
+	al, _ := media.saveAlbum(mediaAlbum{Title: "Trénink"})
+	img, err := media.add("trenink.png", encodePNG(t, halves(900, 600)).Bytes(), "Trénink v hale", []string{al.Slug}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := media.add("prihlaska.pdf", []byte("%PDF-1.4"), "Přihláška", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := sanitizeHTML(`<p>[media id="` + img.ID + `"]</p><p>Ke stažení: [media id="` + doc.ID + `"]</p>` +
+		`<p>[gallery album="trenink"]</p><p>[media id="99"]</p>`)
+	got := expandShortcodes(body, false)
+	for _, want := range []string{
+		`<figure class="sc-media">`,
+		`srcset="/media/1-400.jpg 400w, /media/1-800.jpg 800w, /media/1-900.jpg 900w"`,
+		`width="900" height="600" alt="Trénink v hale"`,
+		`<figcaption>Trénink v hale</figcaption>`,
+		`Ke stažení: <a class="sc-file" href="/media/2-prihlaska.pdf" download>prihlaska.pdf – Přihláška</a>`,
+		`<a href="/galerie/trenink">Trénink</a>`,
+		`<img src="/media/1-thumb.jpg"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "99") {
+		t.Errorf("deleted asset rendered:\n%s", got)
+	}
+	if preview := expandShortcodes(body, true); !strings.Contains(preview, `sc-missing">Shortcode [media id=&#34;99&#34;]`) {
+		t.Errorf("preview lacks the missing note:\n%s", preview)
+	}
+
+	body = sanitizeHTML(`<p><img src="/media/1-800.jpg" alt='[media id="` + img.ID + `"]'></p><pre>[gallery album="trenink"]</pre>`)
+	if got := expandShortcodes(body, false); got != body {
+		t.Errorf("expanded outside text:\n%s", got)
+	}
+}
+
+func TestMediaHandler(t *testing.T) {
+	useMediaLibrary(t)
+	t.Setenv("AUDIT_PATH", filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err := users.open(filepath.Join(t.TempDir(), "users.json")); err != nil {
+		t.Fatal(err)
+	}
+	defer users.open(filepath.Join(t.TempDir(), "none.json"))
+	users.set("trener", roleViewer, "heslo-trenera", true)
+	users.set("redaktor", roleEditor, "heslo-redaktora", true)
+	t.Setenv("ADMIN_BASIC_AUTH", "1")
+
+	serve := func(h http.HandlerFunc, user, method, path, contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if user != "" {
+			req.SetBasicAuth(user, "heslo-"+user+"a")
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	for name, data := range map[string][]byte{"zapas.png": encodePNG(t, halves(800, 400)).Bytes(), "skript.js": []byte("alert(1)")} {
+		w, _ := mw.CreateFormFile("files", name)
+		w.Write(data)
+	}
+	mw.WriteField("title", "Zápas")
+	mw.Close()
+
+	if rec := serve(mediaHandler, "", http.MethodGet, "/api/media", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous list: %d", rec.Code)
+	}
+	if rec := serve(mediaHandler, "trener", http.MethodPost, "/api/media/upload", mw.FormDataContentType(), form.Bytes()); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer upload: %d", rec.Code)
+	}
+	rec := serve(mediaHandler, "redaktor", http.MethodPost, "/api/media/upload", mw.FormDataContentType(), form.Bytes())
+	var up struct {
+		Items  []mediaAsset `json:"items"`
+		Errors []string     `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&up); rec.Code != http.StatusCreated || err != nil || len(up.Items) != 1 || len(up.Errors) != 1 {
+		t.Fatalf("upload: %d %+v %v", rec.Code, up, err)
+	}
+	id := up.Items[0].ID
+
+	rec = serve(mediaHandler, "trener", http.MethodGet, "/api/media?q=zapas", "", nil)
+	var list struct {
+		Items []mediaAsset `json:"items"`
+		Total int          `json:"total"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || list.Total != 1 || list.Items[0].Title != "Zápas" {
+		t.Errorf("list: %d %+v %v", rec.Code, list, err)
+	}
+
+	rec = serve(mediaHandler, "redaktor", http.MethodPost, "/api/media/albums/save", "application/x-www-form-urlencoded", []byte("title=Podzim+2026"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("album: %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(mediaHandler, "redaktor", http.MethodPost, "/api/media/edit", "application/x-www-form-urlencoded", []byte("id="+id+"&albums=podzim-2026"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("edit: %d %s", rec.Code, rec.Body)
+	}
+	if a, _ := media.get(id); a.Title != "Zápas" || len(a.Albums) != 1 {
+		t.Errorf("edit changed more than the albums: %+v", a)
+	}
+
+	// The public gallery lists albums with images and renders each of them
+	if rec := serve(galleryHandler, "", http.MethodGet, "/galerie/", "", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `href="/galerie/podzim-2026"`) {
+		t.Errorf("gallery index: %d", rec.Code)
+	}
+	rec = serve(galleryHandler, "", http.MethodGet, "/galerie/podzim-2026", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `src="/media/`+id+`-thumb.jpg"`) || !strings.Contains(rec.Body.String(), "<title>Podzim 2026 | Bizoni UH</title>") {
+		t.Errorf("album page: %d", rec.Code)
+	}
+	if rec := serve(galleryHandler, "", http.MethodGet, "/galerie/nic", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown album: %d", rec.Code)
+	}
+
+	if rec := serve(mediaHandler, "redaktor", http.MethodPost, "/api/media/delete", "application/x-www-form-urlencoded", []byte("id="+id)); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: %d", rec.Code)
+	}
+	if rec := serve(mediaHandler, "trener", http.MethodGet, "/api/media/get?id="+id, "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted asset: %d", rec.Code)
+	}
+	if rec := serve(mediaHandler, "redaktor", http.MethodGet, "/api/media/upload", "", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("wrong method: %d", rec.Code)
+	}
+	if rec := serve(mediaHandler, "trener", http.MethodGet, "/api/media/neznamo", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown path: %d", rec.Code)
+	}
+}
@@ -0,0 +1,1077 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------- Blog post store ----------------
+// Every post is a record in posts.json on the data volume and blog/*.html pages
+// are rendered from those records with the layout in templates/post.html.
+// Listing and routing read the in-memory records only. Pages that have no
+// record yet (written before the store existed) are imported at startup;
+// pages rendered with an older layout, or missing on disk, are regenerated.
+// Posts are removed through /api/blog/delete, not by deleting their files.
+//
+// Each post has a single page, blog/<id>.html; /blog/<slug> is served from it
+// by the router. Slugs a post had before are kept as aliases that redirect to
+// the current URL. <slug>.html copies written by older versions are removed
+// at startup and their names kept as aliases.
+//
+// Only published posts have pages on disk and appear in listings. Drafts,
+// scheduled and archived posts live in the store alone; blogPublisher flips
+// scheduled posts to published once their publish time has passed.
+
+//go:embed templates/post.html
+var postLayoutSrc string
+
+var postLayout = template.Must(template.New("post").Parse(postLayoutSrc))
+
+// postLayoutHash identifies the layout version a page was rendered with.
+// Pages embed absolute URLs, so a different SITE_URL counts as another layout,
+// and so does another sanitizer policy, Markdown renderer or shortcode markup.
+var postLayoutHash = func() string {
+	sum := sha256.Sum256([]byte(postLayoutSrc + "\x00" + shortcodesSrc + "\x00" + siteBaseURL() + "\x00" + sanitizePolicyVersion + "\x00" + markdownVersion))
+	return hex.EncodeToString(sum[:8])
+}()
+
+// Post lifecycle states
+const (
+	postDraft     = "draft"
+	postScheduled = "scheduled"
+	postPublished = "published"
+	postArchived  = "archived"
+)
+
+func validPostStatus(s string) bool {
+	switch s {
+	case postDraft, postScheduled, postPublished, postArchived:
+		return true
+	}
+	return false
+}
+
+// postRecord is the canonical representation of a single blog post
+type postRecord struct {
+	ID          string   `json:"id"`
+	Slug        string   `json:"slug"`
+	Title       string   `json:"title"`
+	Annotation  string   `json:"annotation,omitempty"`
+	Categories  []string `json:"categories,omitempty"`
+	ContentMode string   `json:"content_mode"`
+	Body        string   `json:"body"`
+	Markdown    string   `json:"markdown,omitempty"` // source of markdown posts; Body is rendered from it
+	Image       string   `json:"image"`
+	// ImageSources are the responsive variants of Image, smallest first
+	ImageSources []imageSource `json:"image_sources,omitempty"`
+	// ImageFit is how Image was cut from the upload; nil for older posts,
+	// which were letterboxed
+	ImageFit *imageFit `json:"image_fit,omitempty"`
+	// ImageMeta is the capture date and camera from the upload's EXIF data,
+	// which is removed from every stored file
+	ImageMeta   *imageMeta `json:"image_meta,omitempty"`
+	Status      string     `json:"status"`
+	PublishAt   time.Time  `json:"publish_at"`   // scheduled publish time
+	PublishedAt time.Time  `json:"published_at"` // first time the post went live
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Author      string     `json:"author,omitempty"`     // account that created the post
+	UpdatedBy   string     `json:"updated_by,omitempty"` // account that saved it last
+	// Aliases are former slugs of the post; put maintains them, so callers
+	// cannot add or drop aliases by editing the record
+	Aliases []string `json:"aliases,omitempty"`
+	// Layout is the hash of the layout the page was rendered with; empty for
+	// imported posts whose page is still the original hand-made HTML
+	Layout string `json:"layout,omitempty"`
+}
+
+type postStore struct {
+	mu      sync.RWMutex
+	blogDir string
+	path    string // persisted store file; empty keeps records in memory only
+	byID    map[string]*postRecord
+	index   searchIndex // full-text index of the published posts
+	// reserved maps the IDs handed out by create to their slugs until the
+	// post is stored with put or the reservation is released
+	reserved map[string]string
+}
+
+// errSlugTaken is returned by create for a slug another post has
+var errSlugTaken = errors.New("slug already in use")
+
+var posts postStore
+
+var (
+	reNumericPostFile = regexp.MustCompile(`^(\d{4})\.html$`)
+	rePostFile        = regexp.MustCompile(`^(\d{4}|[a-z0-9-]+)\.html$`)
+	reHasLetter       = regexp.MustCompile(`[a-z]`)
+	reSlug            = regexp.MustCompile(`^[a-z0-9-]+$`)
+	rePostID          = regexp.MustCompile(`^\d{4}$`)
+)
+
+// validSlug reports whether slug can be a post URL; four digits would shadow
+// the numeric URL of another post
+func validSlug(slug string) bool {
+	return reSlug.MatchString(slug) && !rePostID.MatchString(slug)
+}
+
+func postsPath() string {
+	if p := os.Getenv("POSTS_PATH"); p != "" {
+		return p
+	}
+	// Default: next to club.json on the data volume
+	return filepath.Join(filepath.Dir(dataPath()), "posts.json")
+}
+
+// blogDirFor returns the directory holding the blog HTML files of a site root
+func blogDirFor(siteRoot string) string {
+	// For local development, you can override with environment variable
+	if envPath := os.Getenv("REMOTE_BLOG_DIR"); envPath != "" {
+		return envPath
+	}
+	return filepath.Join(siteRoot, "blog")
+}
+
+// open loads the persisted records (if any), imports pages that have no record
+// yet and regenerates stale pages.
+func (s *postStore) open(blogDir, path string) error {
+	if _, err := os.Stat(blogDir); os.IsNotExist(err) {
+		return fmt.Errorf("blog directory not found: %s. Set REMOTE_BLOG_DIR environment variable or ensure blog directory exists", blogDir)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blogDir = blogDir
+	s.path = path
+	s.byID = make(map[string]*postRecord)
+	if path != "" {
+		if b, err := os.ReadFile(path); err == nil {
+			var payload struct {
+				Posts []*postRecord `json:"posts"`
+			}
+			if err := json.Unmarshal(b, &payload); err != nil {
+				return fmt.Errorf("unmarshal posts: %w", err)
+			}
+			for _, p := range payload.Posts {
+				if p.Status == "" {
+					p.Status = postPublished
+				}
+				s.byID[p.ID] = p
+			}
+		}
+	}
+	imported, err := s.importLocked()
+	if err != nil {
+		return err
+	}
+	collapsed, err := s.collapseSlugCopiesLocked()
+	if err != nil {
+		return err
+	}
+	rendered, err := s.renderLocked(false)
+	if err != nil {
+		return err
+	}
+	variants := s.fillImageSourcesLocked()
+	s.index.reset()
+	for _, p := range s.byID {
+		s.index.update(p)
+	}
+	if imported+collapsed+rendered+variants > 0 {
+		log.Printf("blog store: imported=%d collapsed=%d rendered=%d variants=%d total=%d", imported, collapsed, rendered, variants, len(s.byID))
+		return s.saveLocked()
+	}
+	return nil
+}
+
+// importLocked creates records for blog pages that are not in the store yet
+func (s *postStore) importLocked() (int, error) {
+	entries, err := os.ReadDir(s.blogDir)
+	if err != nil {
+		return 0, fmt.Errorf("readdir blog: %w", err)
+	}
+	known := make(map[string]bool)
+	for id, p := range s.byID {
+		known[id] = true
+		if p.Slug != "" {
+			known[p.Slug] = true
+		}
+	}
+	n := 0
+	add := func(id, name string) {
+		path := filepath.Join(s.blogDir, name)
+		p := parsePostFile(path, id)
+		if p == nil || (p.Slug != "" && p.Slug != id && known[p.Slug]) || known[extractBlogID(path, name)] {
+			// Unreadable, or a copy of a known post under an old file name
+			return
+		}
+		s.byID[id] = p
+		known[id] = true
+		if p.Slug != "" {
+			known[p.Slug] = true
+		}
+		n++
+	}
+	// Numeric files are canonical; slug files are usually copies of them
+	var slugFiles []string
+	for _, e := range entries {
+		name := e.Name()
+		if m := reNumericPostFile.FindStringSubmatch(name); m != nil {
+			if !known[m[1]] {
+				add(m[1], name)
+			}
+		} else if rePostFile.MatchString(name) {
+			slugFiles = append(slugFiles, name)
+		}
+	}
+	for _, name := range slugFiles {
+		if id := strings.TrimSuffix(name, ".html"); !known[id] {
+			add(id, name)
+		}
+	}
+	return n, nil
+}
+
+// collapseSlugCopiesLocked removes <slug>.html copies of posts that have a
+// page of their own and keeps their names as aliases, so links to a stale copy
+// redirect to the post instead of serving outdated content.
+func (s *postStore) collapseSlugCopiesLocked() (int, error) {
+	entries, err := os.ReadDir(s.blogDir)
+	if err != nil {
+		return 0, fmt.Errorf("readdir blog: %w", err)
+	}
+	n := 0
+	for _, e := range entries {
+		name := e.Name()
+		key := strings.TrimSuffix(name, ".html")
+		if !rePostFile.MatchString(name) || s.byID[key] != nil {
+			// Not a post page, or the page of a post whose ID is its slug
+			continue
+		}
+		path := filepath.Join(s.blogDir, name)
+		p := s.byID[extractBlogID(path, name)]
+		if p == nil {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			slug := extractSlugFromContent(string(b))
+			for _, cand := range s.byID {
+				if cand.Slug == slug || cand.Slug == key {
+					p = cand
+					break
+				}
+			}
+		}
+		if p == nil {
+			continue
+		}
+		if key != p.Slug {
+			p.Aliases = appendAlias(p.Aliases, key)
+		}
+		if err := os.Remove(path); err != nil {
+			return n, fmt.Errorf("remove slug copy: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// appendAlias adds slug to aliases unless it is already there
+func appendAlias(aliases []string, slug string) []string {
+	for _, a := range aliases {
+		if a == slug {
+			return aliases
+		}
+	}
+	return append(aliases, slug)
+}
+
+// parsePostFile reads a hand-made or previously rendered page into a record
+func parsePostFile(path, id string) *postRecord {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	s := string(b)
+	// Meta values were written HTML-escaped
+	p := &postRecord{
+		ID:          id,
+		Slug:        extractSlugFromContent(s),
+		Title:       html.UnescapeString(extractTitleFromContent(s)),
+		Annotation:  html.UnescapeString(extractAnnotationFromContent(s)),
+		Categories:  extractCategoriesFromContent(s),
+		ContentMode: extractContentModeFromContent(s),
+		Body:        extractBodyFromContent(s),
+		Image:       "/img/blog/" + id + ".png",
+		Status:      postPublished,
+	}
+	// Best known timestamps: the newer of page and image mtime
+	if info, err := os.Stat(path); err == nil {
+		p.UpdatedAt = info.ModTime()
+	}
+	imgPath := filepath.Join(filepath.Dir(filepath.Dir(path)), "img", "blog", id+".png")
+	if info, err := os.Stat(imgPath); err == nil && info.ModTime().After(p.UpdatedAt) {
+		p.UpdatedAt = info.ModTime()
+	}
+	p.CreatedAt = p.UpdatedAt
+	p.PublishedAt = p.UpdatedAt
+	// Pages cloned from the old template repeat its category; keep each once
+	var cats []string
+	seen := make(map[string]bool)
+	for _, c := range p.Categories {
+		c = html.UnescapeString(c)
+		if !seen[c] {
+			seen[c] = true
+			cats = append(cats, c)
+		}
+	}
+	p.Categories = cats
+	return p
+}
+
+// renderLocked writes the pages of published records rendered with another
+// layout or missing on disk; force re-renders every published record.
+func (s *postStore) renderLocked(force bool) (int, error) {
+	n := 0
+	for _, p := range s.byID {
+		if p.Status != postPublished {
+			continue
+		}
+		stale := p.Layout != "" && p.Layout != postLayoutHash
+		if _, err := os.Stat(filepath.Join(s.blogDir, p.ID+".html")); err != nil {
+			stale = true
+		}
+		if !force && !stale {
+			continue
+		}
+		if err := s.writePageLocked(p); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// postPage is the data the post layout is executed with
+type postPage struct {
+	*postRecord
+	Body        template.HTML
+	Preview     bool
+	URL         string // absolute canonical URL
+	ImageURL    string
+	Description string // annotation, or the beginning of the text
+	Published   string // RFC 3339
+	Modified    string // RFC 3339
+	JSONLD      template.JS
+}
+
+// renderPage executes the post layout for p; preview pages are served from
+// the admin API, so they get a <base> for the relative asset links and noindex
+func renderPage(p *postRecord, preview bool) ([]byte, error) {
+	base := siteBaseURL()
+	page := postPage{
+		postRecord:  p,
+		Body:        template.HTML(expandShortcodes(p.bodyHTML(), preview)),
+		Preview:     preview,
+		URL:         base + p.item().Link,
+		ImageURL:    base + "/img/blog/" + p.ID + ".png",
+		Description: p.summary(),
+		Modified:    p.UpdatedAt.Format(time.RFC3339),
+	}
+	published := p.PublishedAt
+	if published.IsZero() {
+		published = p.UpdatedAt
+	}
+	page.Published = published.Format(time.RFC3339)
+	// schema.org NewsArticle; json.Marshal escapes <, > and &, so the block
+	// cannot end the script element early
+	ld, err := json.Marshal(map[string]any{
+		"@context":         "https://schema.org",
+		"@type":            "NewsArticle",
+		"headline":         p.Title,
+		"description":      page.Description,
+		"image":            []string{page.ImageURL},
+		"datePublished":    page.Published,
+		"dateModified":     page.Modified,
+		"url":              page.URL,
+		"mainEntityOfPage": map[string]string{"@type": "WebPage", "@id": page.URL},
+		"articleSection":   p.Categories,
+		"inLanguage":       "cs",
+		"author":           map[string]string{"@type": "Organization", "name": feedTitle, "url": base + "/"},
+		"publisher": map[string]any{
+			"@type": "Organization",
+			"name":  feedTitle,
+			"logo":  map[string]string{"@type": "ImageObject", "url": base + "/img/logo.png"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render post %s: %w", p.ID, err)
+	}
+	page.JSONLD = template.JS(ld)
+	var buf bytes.Buffer
+	if err := postLayout.Execute(&buf, page); err != nil {
+		return nil, fmt.Errorf("render post %s: %w", p.ID, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writePageLocked renders p to <id>.html
+func (s *postStore) writePageLocked(p *postRecord) error {
+	b, err := renderPage(p, false)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.blogDir, p.ID+".html"), b); err != nil {
+		return err
+	}
+	p.Layout = postLayoutHash
+	return nil
+}
+
+// removePagesLocked deletes the rendered page of p, keeping its record
+func (s *postStore) removePagesLocked(p *postRecord) {
+	_ = os.Remove(filepath.Join(s.blogDir, p.ID+".html"))
+	p.Layout = ""
+}
+
+// backfill renders the pages of published posts that still carry their
+// original hand-made HTML or an older layout, so every page gets the current
+// head tags (Open Graph, Twitter card, JSON-LD). Up-to-date pages are kept.
+func (s *postStore) backfill() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, p := range s.byID {
+		if p.Status != postPublished || p.Layout == postLayoutHash {
+			continue
+		}
+		if err := s.writePageLocked(p); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, s.saveLocked()
+}
+
+// renderAll regenerates every page from its record, e.g. after a layout change
+func (s *postStore) renderAll() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := s.renderLocked(true)
+	if err != nil {
+		return n, err
+	}
+	return n, s.saveLocked()
+}
+
+// servesDir reports whether the store has been opened for blogDir
+func (s *postStore) servesDir(blogDir string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.byID != nil && s.blogDir == blogDir
+}
+
+// get returns a copy of the record with the given ID
+func (s *postStore) get(id string) (postRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.byID[id]
+	if !ok {
+		return postRecord{}, false
+	}
+	return *p, true
+}
+
+// put stores p, renders or withdraws its page according to its status,
+// persists the store and returns the stored record
+func (s *postStore) put(p postRecord) (postRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byID == nil {
+		return postRecord{}, fmt.Errorf("blog store not opened")
+	}
+	if !validPostStatus(p.Status) {
+		return postRecord{}, fmt.Errorf("invalid post status %q", p.Status)
+	}
+	now := time.Now()
+	p.Aliases = nil
+	old := s.byID[p.ID]
+	if old != nil {
+		p.CreatedAt = old.CreatedAt
+		p.PublishedAt = old.PublishedAt
+		if old.Author != "" {
+			p.Author = old.Author
+		}
+		// Keep the former slug so shared links keep working
+		for _, a := range old.Aliases {
+			if a != p.Slug {
+				p.Aliases = append(p.Aliases, a)
+			}
+		}
+		if old.Slug != "" && old.Slug != p.Slug && old.Slug != p.ID {
+			p.Aliases = appendAlias(p.Aliases, old.Slug)
+		}
+		if old.Status == postPublished && p.Status != postPublished {
+			s.removePagesLocked(old)
+		}
+	} else {
+		p.CreatedAt = now
+	}
+	p.UpdatedAt = now
+	if err := s.applyStatusLocked(&p, now); err != nil {
+		return postRecord{}, err
+	}
+	s.byID[p.ID] = &p
+	s.index.update(&p)
+	if err := s.saveLocked(); err != nil {
+		s.undoPutLocked(&p, old)
+		return postRecord{}, err
+	}
+	delete(s.reserved, p.ID)
+	return p, nil
+}
+
+// undoPutLocked brings the record, index and page back to prev (nil for a
+// new post) when the store holding p could not be saved
+func (s *postStore) undoPutLocked(p, prev *postRecord) {
+	if p.Status == postPublished {
+		s.removePagesLocked(p)
+	}
+	if prev == nil {
+		delete(s.byID, p.ID)
+		s.index.remove(p.ID)
+		return
+	}
+	s.byID[p.ID] = prev
+	s.index.update(prev)
+	if prev.Status == postPublished {
+		if err := s.writePageLocked(prev); err != nil {
+			log.Printf("restore page %s: %v", prev.ID, err)
+		}
+	}
+}
+
+// applyStatusLocked renders the page of a published post and clears the
+// publish time of anything that is not scheduled
+func (s *postStore) applyStatusLocked(p *postRecord, now time.Time) error {
+	if p.Status != postScheduled {
+		p.PublishAt = time.Time{}
+	}
+	if p.Status != postPublished {
+		p.Layout = ""
+		return nil
+	}
+	if p.PublishedAt.IsZero() {
+		p.PublishedAt = now
+	}
+	return s.writePageLocked(p)
+}
+
+// blogPublisher publishes scheduled posts once their time has come
+func blogPublisher(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(time.Minute):
+			ids, err := posts.publishDue(time.Now())
+			if err != nil {
+				log.Printf("blog publisher error: %v", err)
+			}
+			if len(ids) > 0 {
+				log.Printf("published scheduled posts: %s", strings.Join(ids, ", "))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// publishDue publishes scheduled posts whose publish time has passed and
+// returns their IDs
+func (s *postStore) publishDue(now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, p := range s.byID {
+		if p.Status != postScheduled || p.PublishAt.After(now) {
+			continue
+		}
+		p.Status = postPublished
+		p.UpdatedAt = now
+		if err := s.applyStatusLocked(p, now); err != nil {
+			return ids, err
+		}
+		s.index.update(p)
+		ids = append(ids, p.ID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return ids, s.saveLocked()
+}
+
+// remove deletes the post identified by ID or slug together with its pages and
+// image, and returns the removed record.
+func (s *postStore) remove(key string) (postRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.byID[key]
+	if p == nil {
+		for _, cand := range s.byID {
+			if cand.Slug == key {
+				p = cand
+				break
+			}
+		}
+	}
+	if p == nil {
+		return postRecord{}, os.ErrNotExist
+	}
+	s.removePagesLocked(p)
+	imgPath := filepath.Join(filepath.Dir(s.blogDir), "img", "blog", p.ID+".png")
+	_ = os.Remove(imgPath)
+	removeImageVariants(imgPath)
+	removeOriginalImages(p.ID)
+	delete(s.byID, p.ID)
+	s.index.remove(p.ID)
+	return *p, s.saveLocked()
+}
+
+// nextIDLocked returns the next free numeric post ID; callers hold s.mu
+func (s *postStore) nextIDLocked() string {
+	max := -1
+	for id := range s.byID {
+		if n, err := strconv.Atoi(id); err == nil && n > max {
+			max = n
+		}
+	}
+	for id := range s.reserved {
+		if n, err := strconv.Atoi(id); err == nil && n > max {
+			max = n
+		}
+	}
+	return fmt.Sprintf("%04d", max+1)
+}
+
+// create reserves the next free ID and a slug for a new post: slug if given
+// (errSlugTaken if another post has it), else a unique one derived from
+// title. The post is then stored with put, or the reservation dropped with
+// release, so concurrent creates never share an ID or slug.
+func (s *postStore) create(slug, title string) (id, finalSlug string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slug != "" {
+		if s.slugTakenLocked(slug, "") {
+			return "", "", errSlugTaken
+		}
+		finalSlug = slug
+	} else {
+		base := generateSlug(title)
+		if !validSlug(base) {
+			base = strings.Trim("clanek-"+base, "-")
+		}
+		finalSlug = s.uniqueSlugLocked(base)
+	}
+	id = s.nextIDLocked()
+	if s.reserved == nil {
+		s.reserved = make(map[string]string)
+	}
+	s.reserved[id] = finalSlug
+	return id, finalSlug, nil
+}
+
+// release drops the reservation of an ID from create that was not stored
+func (s *postStore) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reserved, id)
+}
+
+// uniqueSlugLocked ensures the slug is unique by appending a number if
+// needed; callers hold s.mu
+func (s *postStore) uniqueSlugLocked(baseSlug string) string {
+	taken := make(map[string]bool)
+	for id, p := range s.byID {
+		taken[id] = true
+		taken[p.Slug] = true
+		for _, a := range p.Aliases {
+			taken[a] = true
+		}
+	}
+	for id, slug := range s.reserved {
+		taken[id] = true
+		taken[slug] = true
+	}
+	if !taken[baseSlug] {
+		return baseSlug
+	}
+	// Try baseSlug-2, baseSlug-3, etc.
+	for i := 2; i < 100; i++ {
+		testSlug := fmt.Sprintf("%s-%d", baseSlug, i)
+		if !taken[testSlug] {
+			return testSlug
+		}
+	}
+	// Fallback to timestamp
+	return fmt.Sprintf("%s-%d", baseSlug, time.Now().Unix())
+}
+
+// lookupSlug returns the ID of the published post with the given slug
+func (s *postStore) lookupSlug(slug string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for id, p := range s.byID {
+		if p.Slug == slug && p.Status == postPublished {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// slugTaken reports whether slug is the ID, slug or alias of a post other
+// than exceptID, or reserved for a post being created
+func (s *postStore) slugTaken(slug, exceptID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.slugTakenLocked(slug, exceptID)
+}
+
+func (s *postStore) slugTakenLocked(slug, exceptID string) bool {
+	for id, p := range s.byID {
+		if id == exceptID {
+			continue
+		}
+		if id == slug || p.Slug == slug {
+			return true
+		}
+		for _, a := range p.Aliases {
+			if a == slug {
+				return true
+			}
+		}
+	}
+	for id, reserved := range s.reserved {
+		if id != exceptID && (id == slug || reserved == slug) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAlias drops a former slug of a post, so it no longer redirects and
+// can be given to another post
+func (s *postStore) removeAlias(id, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.byID[id]
+	if p == nil {
+		return os.ErrNotExist
+	}
+	for i, a := range p.Aliases {
+		if a == slug {
+			p.Aliases = append(p.Aliases[:i:i], p.Aliases[i+1:]...)
+			return s.saveLocked()
+		}
+	}
+	return os.ErrNotExist
+}
+
+// lookupAlias returns the ID of the published post that formerly had the
+// given slug
+func (s *postStore) lookupAlias(slug string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for id, p := range s.byID {
+		if p.Status != postPublished {
+			continue
+		}
+		for _, a := range p.Aliases {
+			if a == slug {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+// list returns up to limit published posts (0 = all), newest first
+func (s *postStore) list(limit int) []BlogItem {
+	items := s.listStatus(postPublished)
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// listStatus returns posts in the given status (empty = any), newest first.
+// Status fields are only filled in for admin listings (status == "").
+func (s *postStore) listStatus(status string) []BlogItem {
+	s.mu.RLock()
+	items := make([]BlogItem, 0, len(s.byID))
+	for _, p := range s.byID {
+		if status != "" && p.Status != status {
+			continue
+		}
+		it := p.item()
+		if status == "" {
+			it.Author = p.Author
+			it.Status = p.Status
+			if !p.PublishAt.IsZero() {
+				t := p.PublishAt
+				it.PublishAt = &t
+			}
+		}
+		items = append(items, it)
+	}
+	s.mu.RUnlock()
+	sortBlogItems(items)
+	return items
+}
+
+// postQuery selects and orders published posts for public listings
+type postQuery struct {
+	Category string    // name or slug, case and diacritics insensitive; empty = any
+	After    time.Time // published at or after; zero = no bound
+	Before   time.Time // published before; zero = no bound
+	Sort     string    // one of postSorts; empty = newest
+}
+
+// postSorts are the accepted postQuery.Sort values
+var postSorts = map[string]bool{"newest": true, "oldest": true, "updated": true, "title": true}
+
+// query returns the published posts matching q, ordered by q.Sort
+func (s *postStore) query(q postQuery) []BlogItem {
+	cat := foldCzech(strings.TrimSpace(q.Category))
+	s.mu.RLock()
+	items := make([]BlogItem, 0, len(s.byID))
+	for _, p := range s.byID {
+		if p.Status != postPublished {
+			continue
+		}
+		if !q.After.IsZero() && p.PublishedAt.Before(q.After) {
+			continue
+		}
+		if !q.Before.IsZero() && !p.PublishedAt.Before(q.Before) {
+			continue
+		}
+		if cat != "" && !hasCategory(p.Categories, cat) {
+			continue
+		}
+		items = append(items, p.item())
+	}
+	s.mu.RUnlock()
+	sortBlogItems(items)
+	switch q.Sort {
+	case "oldest":
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	case "updated":
+		sort.SliceStable(items, func(i, j int) bool { return items[i].MTime.After(items[j].MTime) })
+	case "title":
+		sort.SliceStable(items, func(i, j int) bool { return foldCzech(items[i].Title) < foldCzech(items[j].Title) })
+	}
+	return items
+}
+
+// hasCategory reports whether cats contains the folded category name or slug
+func hasCategory(cats []string, folded string) bool {
+	for _, c := range cats {
+		if foldCzech(c) == folded || generateSlug(c) == folded {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryCount is a category with the number of published posts in it
+type categoryCount struct {
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Count int    `json:"count"`
+}
+
+// categories returns the categories of published posts, most used first.
+// Spellings differing only in case or diacritics are counted together under
+// the most common one.
+func (s *postStore) categories() []categoryCount {
+	s.mu.RLock()
+	counts := make(map[string]int)               // folded name -> posts
+	spellings := make(map[string]map[string]int) // folded name -> spelling -> uses
+	for _, p := range s.byID {
+		if p.Status != postPublished {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, c := range p.Categories {
+			f := foldCzech(strings.TrimSpace(c))
+			if f == "" || seen[f] {
+				continue
+			}
+			seen[f] = true
+			counts[f]++
+			if spellings[f] == nil {
+				spellings[f] = make(map[string]int)
+			}
+			spellings[f][strings.TrimSpace(c)]++
+		}
+	}
+	s.mu.RUnlock()
+	out := make([]categoryCount, 0, len(counts))
+	for f, n := range counts {
+		name, best := "", 0
+		for sp, uses := range spellings[f] {
+			if uses > best || (uses == best && sp < name) {
+				name, best = sp, uses
+			}
+		}
+		out = append(out, categoryCount{Name: name, Slug: generateSlug(name), Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return foldCzech(out[i].Name) < foldCzech(out[j].Name)
+	})
+	return out
+}
+
+// searchResult is a post matching a search query
+type searchResult struct {
+	BlogItem
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"` // HTML, matches wrapped in <mark>
+}
+
+// search returns up to limit published posts matching query (0 = all), best
+// match first, and the total number of matches
+func (s *postStore) search(query string, limit int) ([]searchResult, int) {
+	hits, total := s.index.search(query, limit)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]searchResult, 0, len(hits))
+	for _, h := range hits {
+		if p := s.byID[h.ID]; p != nil {
+			out = append(out, searchResult{BlogItem: p.item(), Score: h.Score, Snippet: h.Snippet})
+		}
+	}
+	return out, total
+}
+
+// item converts a record to its public list representation
+func (p *postRecord) item() BlogItem {
+	// Use slug-based link if slug exists and is not just numeric, otherwise use canonical numeric ID
+	link := "/blog/" + p.ID + ".html"
+	if p.Slug != "" && reHasLetter.MatchString(p.Slug) {
+		link = "/blog/" + p.Slug
+	}
+	return BlogItem{
+		ID:          p.ID,
+		Title:       p.Title,
+		Slug:        p.Slug,
+		Link:        link,
+		Image:       p.Image,
+		MTime:       p.UpdatedAt,
+		PublishedAt: p.PublishedAt,
+		Description: p.summary(),
+		Categories:  p.Categories,
+		Sources:     p.ImageSources,
+	}
+}
+
+// summary returns the annotation of p, or the beginning of its text for posts
+// written without one
+func (p *postRecord) summary() string {
+	if a := strings.TrimSpace(p.Annotation); a != "" {
+		return a
+	}
+	text := plainText(p.Body)
+	if end := moveRunes(text, 0, 200); end < len(text) {
+		if i := strings.LastIndexByte(text[:end], ' '); i > 0 {
+			end = i
+		}
+		text = text[:end] + "…"
+	}
+	return text
+}
+
+// sortBlogItems orders posts newest first
+func sortBlogItems(items []BlogItem) {
+	sort.Slice(items, func(i, j int) bool {
+		// Always prefer numeric ID descending (higher ID = newer post).
+		// Numeric IDs monotonically increase via postStore.nextID, so they are
+		// the authoritative ordering regardless of file MTime.
+		ii, err1 := strconv.Atoi(items[i].ID)
+		jj, err2 := strconv.Atoi(items[j].ID)
+		if err1 == nil && err2 == nil {
+			return ii > jj
+		}
+		// If only one item has a numeric ID, it is newer
+		if err1 == nil {
+			return true
+		}
+		if err2 == nil {
+			return false
+		}
+		// Both non-numeric: fall back to MTime, then string comparison
+		if !items[i].MTime.Equal(items[j].MTime) {
+			return items[i].MTime.After(items[j].MTime)
+		}
+		return items[i].ID > items[j].ID
+	})
+}
+
+// saveLocked persists the store atomically; callers hold s.mu
+func (s *postStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]*postRecord, 0, len(s.byID))
+	for _, p := range s.byID {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	b, err := json.MarshalIndent(struct {
+		Posts []*postRecord `json:"posts"`
+	}{Posts: list}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	return writeFileAtomic(s.path, b)
+}
+
+// writeFileAtomic writes b to path via a temp file and rename
+func writeFileAtomic(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("write tmp: %w", err)
+	}
+	// On Windows, Rename over existing file may fail; remove target first.
+	_ = os.Remove(path)
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	return nil
+}
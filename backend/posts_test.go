@@ -0,0 +1,372 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPostStoreImportAndRender verifies legacy pages are imported, stored posts
+// are rendered from the layout and the store survives a reopen.
+func TestPostStoreImportAndRender(t *testing.T) {
+	tmpDir := t.TempDir()
+	blogDir := filepath.Join(tmpDir, "blog")
+	os.MkdirAll(blogDir, 0755)
+	legacy := `<html><head><meta name="slug" content="prvni"><meta name="category" content="Z&aacute;pasy"></head><body>
+<h1 class="lte-header">První &amp; nejlepší</h1>
+<div class="text lte-text-page clearfix"><p>Úvod</p><div class="wp-block"><p>Vnořený</p></div><p>Konec</p></div>
+</body></html>`
+	os.WriteFile(filepath.Join(blogDir, "0001.html"), []byte(legacy), 0644)
+	os.WriteFile(filepath.Join(blogDir, "prvni.html"), []byte(legacy), 0644)
+
+	storePath := filepath.Join(tmpDir, "data", "posts.json")
+	var st postStore
+	if err := st.open(blogDir, storePath); err != nil {
+		t.Fatal(err)
+	}
+	p, ok := st.get("0001")
+	if !ok {
+		t.Fatal("legacy post not imported")
+	}
+	if p.Title != "První & nejlepší" || p.Slug != "prvni" || len(p.Categories) != 1 || p.Categories[0] != "Zápasy" {
+		t.Errorf("unexpected metadata: %+v", p)
+	}
+	if !strings.HasSuffix(p.Body, "<p>Konec</p>") {
+		t.Errorf("body cut short: %q", p.Body)
+	}
+	if items := st.list(0); len(items) != 1 {
+		t.Fatalf("slug copy imported as separate post: %+v", items)
+	}
+	if _, err := os.Stat(filepath.Join(blogDir, "prvni.html")); !os.IsNotExist(err) {
+		t.Error("slug copy not collapsed")
+	}
+
+	id, slug, err := st.create("", "První")
+	if err != nil || id != "0002" || slug != "prvni-2" {
+		t.Fatalf("expected 0002 prvni-2, got %s %s %v", id, slug, err)
+	}
+	_, err = st.put(postRecord{ID: id, Slug: slug, Title: "Druhý <zápas>", ContentMode: "visual", Body: "<p>Text</p>", Categories: []string{"Muži"}, Status: postPublished})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(filepath.Join(blogDir, id+".html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<meta name="slug" content="prvni-2">`, `<meta name="category" content="Muži">`, `Druhý &lt;zápas&gt;`, "<p>Text</p>",
+		`<meta property="og:url" content="` + siteBaseURL() + `/blog/prvni-2">`,
+		`<meta property="og:image" content="` + siteBaseURL() + `/img/blog/0002.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`"@type":"NewsArticle"`, `"headline":"Druhý \u003czápas\u003e"`,
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("rendered page missing %q", want)
+		}
+	}
+
+	var reopened postStore
+	if err := reopened.open(blogDir, storePath); err != nil {
+		t.Fatal(err)
+	}
+	if items := reopened.list(0); len(items) != 2 || items[0].ID != id || items[0].Link != "/blog/prvni-2" {
+		t.Fatalf("unexpected items after reopen: %+v", items)
+	}
+	if removed, err := reopened.remove("prvni-2"); err != nil || removed.ID != id {
+		t.Fatalf("remove by slug: %s, %v", removed.ID, err)
+	}
+	if _, err := os.Stat(filepath.Join(blogDir, id+".html")); !os.IsNotExist(err) {
+		t.Errorf("page of removed post still on disk")
+	}
+}
+
+// TestPostStoreScheduling verifies unpublished posts stay off disk and out of
+// listings until the publisher releases them.
+func TestPostStoreScheduling(t *testing.T) {
+	tmpDir := t.TempDir()
+	blogDir := filepath.Join(tmpDir, "blog")
+	os.MkdirAll(blogDir, 0755)
+	var st postStore
+	if err := st.open(blogDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now().Add(time.Hour)
+	if _, err := st.put(postRecord{ID: "0001", Slug: "po-zapase", Title: "Po zápase", Body: "<p>3:2</p>", Status: postScheduled, PublishAt: at}); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(blogDir, "0001.html")
+	if _, err := os.Stat(page); !os.IsNotExist(err) {
+		t.Fatal("scheduled post rendered before its publish time")
+	}
+	if len(st.list(0)) != 0 {
+		t.Fatal("scheduled post listed publicly")
+	}
+	if _, ok := st.lookupSlug("po-zapase"); ok {
+		t.Fatal("scheduled post routable by slug")
+	}
+	if ids, _ := st.publishDue(time.Now()); len(ids) != 0 {
+		t.Fatalf("published too early: %v", ids)
+	}
+	if ids, err := st.publishDue(at.Add(time.Second)); err != nil || len(ids) != 1 {
+		t.Fatalf("publishDue: %v, %v", ids, err)
+	}
+	if _, err := os.Stat(page); err != nil {
+		t.Fatal("published post has no page")
+	}
+
+	// Unpublishing keeps the record but withdraws the page
+	p, _ := st.get("0001")
+	p.Status = postArchived
+	if _, err := st.put(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(page); !os.IsNotExist(err) {
+		t.Fatal("archived post still has a page")
+	}
+	if p, ok := st.get("0001"); !ok || p.Body != "<p>3:2</p>" || p.PublishedAt.IsZero() {
+		t.Fatalf("archived post lost content: %+v", p)
+	}
+}
+
+// TestPostStoreSlugAliases verifies a changed slug stays resolvable as an
+// alias and stale slug copies from older versions become aliases.
+func TestPostStoreSlugAliases(t *testing.T) {
+	tmpDir := t.TempDir()
+	blogDir := filepath.Join(tmpDir, "blog")
+	os.MkdirAll(blogDir, 0755)
+	storePath := filepath.Join(tmpDir, "posts.json")
+	var st postStore
+	if err := st.open(blogDir, storePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.put(postRecord{ID: "0001", Slug: "zapas", Title: "Zápas", Status: postPublished}); err != nil {
+		t.Fatal(err)
+	}
+	// A copy left behind by a slug change in an older version
+	stale := `<html><head><meta name="id" content="0001"><meta name="slug" content="stary"></head><body><h1 class="lte-header">Zápas</h1></body></html>`
+	os.WriteFile(filepath.Join(blogDir, "stary.html"), []byte(stale), 0644)
+	if err := st.open(blogDir, storePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(blogDir, "stary.html")); !os.IsNotExist(err) {
+		t.Fatal("stale slug copy kept")
+	}
+	p, _ := st.get("0001")
+	p.Slug = "zapas-3-2"
+	if _, err := st.put(p); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(blogDir)
+	if len(entries) != 1 || entries[0].Name() != "0001.html" {
+		t.Fatalf("expected a single page, got %v", entries)
+	}
+	for _, old := range []string{"stary", "zapas"} {
+		if id, ok := st.lookupAlias(old); !ok || id != "0001" {
+			t.Errorf("alias %q not resolved", old)
+		}
+	}
+	if _, ok := st.lookupSlug("zapas-3-2"); !ok {
+		t.Error("current slug not resolved")
+	}
+	if id, got, err := st.create("", "Zápas"); err != nil || got == "zapas" {
+		t.Errorf("alias handed out as a new slug: %q %v", got, err)
+	} else {
+		st.release(id)
+	}
+	if _, _, err := st.create("zapas", ""); !errors.Is(err, errSlugTaken) {
+		t.Errorf("alias accepted as a given slug: %v", err)
+	}
+	// Changing the slug back drops it from the aliases
+	p, _ = st.get("0001")
+	p.Slug = "zapas"
+	if p, _ = st.put(p); len(p.Aliases) != 2 || p.Aliases[0] != "stary" || p.Aliases[1] != "zapas-3-2" {
+		t.Errorf("unexpected aliases: %v", p.Aliases)
+	}
+}
+
+// TestPostStoreAliasRemoval verifies aliases block other posts until removed.
+func TestPostStoreAliasRemoval(t *testing.T) {
+	tmpDir := t.TempDir()
+	var st postStore
+	if err := st.open(tmpDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.put(postRecord{ID: "0001", Slug: "puvodni", Status: postPublished}); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := st.get("0001")
+	p.Slug = "nova"
+	st.put(p)
+	if !st.slugTaken("puvodni", "") || st.slugTaken("puvodni", "0001") {
+		t.Fatal("alias ownership not reported")
+	}
+	if err := st.removeAlias("0001", "puvodni"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := st.lookupAlias("puvodni"); ok || st.slugTaken("puvodni", "") {
+		t.Fatal("removed alias still resolves")
+	}
+	if err := st.removeAlias("0001", "puvodni"); !os.IsNotExist(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+// TestPostStoreQueryAndCategories verifies category and date filters, sorting
+// and category counts of public listings.
+func TestPostStoreQueryAndCategories(t *testing.T) {
+	var st postStore
+	if err := st.open(t.TempDir(), ""); err != nil {
+		t.Fatal(err)
+	}
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 12, 0, 0, 0, time.UTC) }
+	for i, p := range []postRecord{
+		{Slug: "a", Title: "Cvičení", Categories: []string{"Zápasy"}},
+		{Slug: "b", Title: "Bizoni", Categories: []string{"zapasy", "O nás"}},
+		{Slug: "c", Title: "Akce", Categories: []string{"O nás"}},
+		{Slug: "d", Title: "Koncept", Categories: []string{"Zápasy"}, Status: postDraft},
+	} {
+		p.ID = fmt.Sprintf("%04d", i+1)
+		if p.Status == "" {
+			p.Status = postPublished
+		}
+		p.PublishedAt = day(i + 1)
+		if _, err := st.put(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(items []BlogItem) string {
+		var s []string
+		for _, it := range items {
+			s = append(s, it.ID)
+		}
+		return strings.Join(s, ",")
+	}
+	for _, tc := range []struct {
+		q    postQuery
+		want string
+	}{
+		{postQuery{}, "0003,0002,0001"},
+		{postQuery{Category: "ZÁPASY"}, "0002,0001"},
+		{postQuery{Category: "o-nas", Sort: "oldest"}, "0002,0003"},
+		{postQuery{After: day(2), Before: day(3)}, "0002"},
+		{postQuery{Sort: "title"}, "0003,0002,0001"},
+	} {
+		if got := ids(st.query(tc.q)); got != tc.want {
+			t.Errorf("query %+v = %s, want %s", tc.q, got, tc.want)
+		}
+	}
+	cats := st.categories()
+	if len(cats) != 2 || cats[0].Count != 2 || cats[1].Count != 2 || cats[0].Name != "O nás" || cats[0].Slug != "o-nas" {
+		t.Errorf("unexpected categories: %+v", cats)
+	}
+}
+
+// TestPostStoreCreateReserves verifies concurrent creates get distinct IDs
+// and slugs before any of them is stored.
+func TestPostStoreCreateReserves(t *testing.T) {
+	var st postStore
+	if err := st.open(t.TempDir(), ""); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	ids := make([]string, 8)
+	slugs := make([]string, 8)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i], slugs[i], _ = st.create("", "Zápas")
+		}()
+	}
+	wg.Wait()
+	seen := map[string]bool{}
+	for i := range ids {
+		if ids[i] == "" || seen[ids[i]] || seen[slugs[i]] {
+			t.Fatalf("shared or missing reservation: %v %v", ids, slugs)
+		}
+		seen[ids[i]], seen[slugs[i]] = true, true
+	}
+	if _, _, err := st.create(slugs[0], "Jiný"); !errors.Is(err, errSlugTaken) {
+		t.Errorf("reserved slug handed out again: %v", err)
+	}
+	// Stored and released reservations no longer count
+	if _, err := st.put(postRecord{ID: ids[0], Slug: slugs[0], Title: "Zápas", Body: "<p>x</p>", Status: postDraft}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids[1:] {
+		st.release(id)
+	}
+	if id, slug, err := st.create("", "Zápas"); err != nil || id == ids[0] || slug == slugs[0] || !strings.HasPrefix(slug, "zapas") {
+		t.Errorf("after release: %s %s %v", id, slug, err)
+	}
+}
+
+// TestPostStorePutRollback verifies a put whose store cannot be saved leaves
+// the record, the search index and the page as they were
+func TestPostStorePutRollback(t *testing.T) {
+	dir := t.TempDir()
+	blogDir := filepath.Join(dir, "blog")
+	os.MkdirAll(blogDir, 0755)
+	var st postStore
+	if err := st.open(blogDir, filepath.Join(dir, "posts.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.put(postRecord{ID: "0001", Slug: "vyhra", Title: "Výhra", Body: "<p>Derby</p>", Status: postPublished}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file where the store's directory should be makes every save fail
+	os.WriteFile(filepath.Join(dir, "soubor"), nil, 0644)
+	st.path = filepath.Join(dir, "soubor", "posts.json")
+	if _, err := st.put(postRecord{ID: "0001", Slug: "prohra", Title: "Prohra", Body: "<p>Pohár</p>", Status: postDraft}); err == nil {
+		t.Fatal("put succeeded without saving")
+	}
+	if _, err := st.put(postRecord{ID: "0002", Slug: "novy", Title: "Nový", Body: "<p>Pohár</p>", Status: postPublished}); err == nil {
+		t.Fatal("put succeeded without saving")
+	}
+	if p, _ := st.get("0001"); p.Title != "Výhra" || p.Status != postPublished || len(p.Aliases) != 0 {
+		t.Errorf("record changed: %+v", p)
+	}
+	if _, ok := st.get("0002"); ok {
+		t.Error("new post kept in memory")
+	}
+	if _, ok := st.lookupAlias("vyhra"); ok {
+		t.Error("alias kept in memory")
+	}
+	if _, total := st.search("pohár", 10); total != 0 {
+		t.Errorf("search index holds %d unsaved posts", total)
+	}
+	if _, total := st.search("derby", 10); total != 1 {
+		t.Errorf("search index lost the saved post: %d", total)
+	}
+	if page, err := os.ReadFile(filepath.Join(blogDir, "0001.html")); err != nil || !strings.Contains(string(page), "Výhra") {
+		t.Errorf("page not restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(blogDir, "0002.html")); !os.IsNotExist(err) {
+		t.Error("page of the unsaved post left behind")
+	}
+}
+
+func TestValidSlug(t *testing.T) {
+	for slug, want := range map[string]bool{
+		"vyhra-3-2": true, "2024-finale": true, "12345": true,
+		"": false, "0042": false, "a b": false, "vyhra/x": false, "ü": false, "Vyhra": false, "x?y": false,
+	} {
+		if got := validSlug(slug); got != want {
+			t.Errorf("validSlug(%q) = %v", slug, got)
+		}
+	}
+	// A slug derived from a title is always valid
+	var st postStore
+	if err := st.open(t.TempDir(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, slug, err := st.create("", "2024"); err != nil || slug != "clanek-2024" {
+		t.Errorf("numeric title: %q %v", slug, err)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ---------------- Image resampling ----------------
+// Images are scaled with a separable filter on the RGBA pixel buffer: one
+// pass along the rows into a float buffer, one along the columns into the
+// result. Colors are premultiplied by alpha, so transparent edges do not
+// bleed. Large images are split into bands of rows that are processed in
+// parallel.
+//
+// IMAGE_RESAMPLE_FILTER picks the filter (bilinear, catmull-rom, lanczos;
+// default catmull-rom). Uploads smaller than the target are enlarged by at
+// most IMAGE_MAX_UPSCALE (default 1.5, 1 keeps them at their size).
+
+// resampleFilter is a separable interpolation kernel
+type resampleFilter struct {
+	name    string
+	support float64 // kernel radius in source pixels when enlarging
+	kernel  func(x float64) float64
+}
+
+var resampleFilters = []resampleFilter{
+	{"bilinear", 1, func(x float64) float64 {
+		x = math.Abs(x)
+		if x < 1 {
+			return 1 - x
+		}
+		return 0
+	}},
+	{"catmull-rom", 2, func(x float64) float64 {
+		// Cubic with B=0, C=0.5
+		x = math.Abs(x)
+		switch {
+		case x < 1:
+			return 1.5*x*x*x - 2.5*x*x + 1
+		case x < 2:
+			return -0.5*x*x*x + 2.5*x*x - 4*x + 2
+		}
+		return 0
+	}},
+	{"lanczos", 3, func(x float64) float64 {
+		x = math.Abs(x)
+		switch {
+		case x == 0:
+			return 1
+		case x < 3:
+			px := math.Pi * x
+			return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+		}
+		return 0
+	}},
+}
+
+const (
+	defaultResampleFilter = "catmull-rom"
+	defaultMaxUpscale     = 1.5
+	// resampleParallelMin is the output size in pixels from which bands are
+	// processed in parallel
+	resampleParallelMin = 256 * 256
+)
+
+// imageResampleFilter returns the filter named by IMAGE_RESAMPLE_FILTER
+func imageResampleFilter() resampleFilter {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("IMAGE_RESAMPLE_FILTER")))
+	if name == "" {
+		name = defaultResampleFilter
+	}
+	for _, f := range resampleFilters {
+		if f.name == name {
+			return f
+		}
+	}
+	return resampleFilters[1]
+}
+
+// imageMaxUpscale returns how much small uploads may be enlarged
+func imageMaxUpscale() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("IMAGE_MAX_UPSCALE"), 64); err == nil && v >= 1 {
+		return v
+	}
+	return defaultMaxUpscale
+}
+
+// resampleWeights are the contributions of source pixels start.. to one
+// destination pixel
+type resampleWeights struct {
+	start   int
+	weights []float32
+}
+
+// resampleKernel computes the weights for scaling srcN pixels to dstN
+func resampleKernel(srcN, dstN int, f resampleFilter) []resampleWeights {
+	scale := float64(srcN) / float64(dstN)
+	// When shrinking, the kernel is stretched over scale source pixels
+	stretch := math.Max(scale, 1)
+	support := f.support * stretch
+	out := make([]resampleWeights, dstN)
+	for i := range out {
+		center := (float64(i)+0.5)*scale - 0.5
+		lo := max(int(math.Ceil(center-support)), 0)
+		hi := min(int(math.Floor(center+support)), srcN-1)
+		ws := make([]float32, 0, hi-lo+1)
+		var sum float64
+		for j := lo; j <= hi; j++ {
+			w := f.kernel((float64(j) - center) / stretch)
+			ws = append(ws, float32(w))
+			sum += w
+		}
+		if sum == 0 {
+			// Only possible for degenerate sizes; fall back to the nearest pixel
+			n := min(max(int(math.Round(center)), 0), srcN-1)
+			out[i] = resampleWeights{start: n, weights: []float32{1}}
+			continue
+		}
+		for k := range ws {
+			ws[k] /= float32(sum)
+		}
+		out[i] = resampleWeights{start: lo, weights: ws}
+	}
+	return out
+}
+
+// resample scales src to dw x dh with filter f, up or down
+func resample(src image.Image, dw, dh int, f resampleFilter) *image.RGBA {
+	rgba := toRGBA(src)
+	sw, sh := rgba.Rect.Dx(), rgba.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	if sw == 0 || sh == 0 || dw == 0 || dh == 0 {
+		return dst
+	}
+	xw := resampleKernel(sw, dw, f)
+	yw := resampleKernel(sh, dh, f)
+	parallel := dw*dh >= resampleParallelMin
+
+	// Rows: src (sw x sh) -> tmp (dw x sh)
+	tmp := make([]float32, dw*sh*4)
+	parallelBands(sh, parallel, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			row := rgba.Pix[y*rgba.Stride:]
+			out := tmp[y*dw*4:]
+			for x, cw := range xw {
+				var r, g, b, a float32
+				p := cw.start * 4
+				for _, w := range cw.weights {
+					r += w * float32(row[p])
+					g += w * float32(row[p+1])
+					b += w * float32(row[p+2])
+					a += w * float32(row[p+3])
+					p += 4
+				}
+				o := x * 4
+				out[o], out[o+1], out[o+2], out[o+3] = r, g, b, a
+			}
+		}
+	})
+
+	// Columns: tmp (dw x sh) -> dst (dw x dh)
+	parallelBands(dh, parallel, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			cw := yw[y]
+			out := dst.Pix[y*dst.Stride:]
+			for x := 0; x < dw; x++ {
+				var r, g, b, a float32
+				p := (cw.start*dw + x) * 4
+				for _, w := range cw.weights {
+					r += w * tmp[p]
+					g += w * tmp[p+1]
+					b += w * tmp[p+2]
+					a += w * tmp[p+3]
+					p += dw * 4
+				}
+				// Ringing of the sharper filters can leave the range and
+				// colors may not exceed alpha in premultiplied form
+				alpha := clampByte(a)
+				o := x * 4
+				out[o] = min(clampByte(r), alpha)
+				out[o+1] = min(clampByte(g), alpha)
+				out[o+2] = min(clampByte(b), alpha)
+				out[o+3] = alpha
+			}
+		}
+	})
+	return dst
+}
+
+// toRGBA returns img as an RGBA buffer starting at 0,0
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+func clampByte(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// parallelBands calls fn for bands of the rows 0..n, concurrently across the
+// available CPUs when parallel is set
+func parallelBands(n int, parallel bool, fn func(y0, y1 int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if !parallel || workers < 2 || n < 2*workers {
+		fn(0, n)
+		return
+	}
+	band := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for y0 := 0; y0 < n; y0 += band {
+		wg.Add(1)
+		go func(y0, y1 int) {
+			defer wg.Done()
+			fn(y0, y1)
+		}(y0, min(y0+band, n))
+	}
+	wg.Wait()
+}
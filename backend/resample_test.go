@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestFitWithin(t *testing.T) {
+	cases := []struct {
+		sw, sh     int
+		maxUpscale float64
+		w, h       int
+	}{
+		{4000, 3000, 1.5, 1292, 969}, // phone photo, limited by height
+		{3200, 969, 1.5, 1600, 484},  // panorama, limited by width
+		{800, 600, 1, 800, 600},      // upscaling disabled
+		{800, 600, 1.5, 1200, 900},   // enlarged up to the limit
+		{1000, 600, 4, 1600, 960},    // enlarged until it fits
+	}
+	for _, tc := range cases {
+		if w, h := fitWithin(tc.sw, tc.sh, blogImgW, blogImgH, tc.maxUpscale); w != tc.w || h != tc.h {
+			t.Errorf("fitWithin(%d, %d, %v) = %dx%d, want %dx%d", tc.sw, tc.sh, tc.maxUpscale, w, h, tc.w, tc.h)
+		}
+	}
+}
+
+func TestResample(t *testing.T) {
+	// A one-pixel checkerboard shrunk to half averages to gray; nearest
+	// neighbour sampling would keep pure black or white
+	checker := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if (x+y)%2 == 0 {
+				checker.Pix[y*checker.Stride+x] = 255
+			}
+		}
+	}
+	for _, f := range resampleFilters {
+		out := resample(checker, 32, 32, f)
+		if v := out.RGBAAt(16, 16).R; v < 112 || v > 144 {
+			t.Errorf("%s: checkerboard shrunk to %d, want gray", f.name, v)
+		}
+		// Flat color stays flat in both directions
+		want := color.RGBA{200, 100, 50, 255}
+		for _, size := range []int{7, 300} {
+			src := image.NewRGBA(image.Rect(0, 0, 40, 30))
+			for i := 0; i < len(src.Pix); i += 4 {
+				copy(src.Pix[i:], []uint8{200, 100, 50, 255})
+			}
+			out := resample(src, size, size, f)
+			if got := out.RGBAAt(size/2, size-1); got != want {
+				t.Errorf("%s to %d: flat color became %v", f.name, size, got)
+			}
+		}
+	}
+}
+
+func TestResampleTransparentEdges(t *testing.T) {
+	// Transparent pixels must not darken or tint their opaque neighbours
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 20; x < 40; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+		}
+	}
+	out := resample(src, 13, 13, resampleFilters[2])
+	for i := 0; i < len(out.Pix); i += 4 {
+		r, a := out.Pix[i], out.Pix[i+3]
+		if r > a {
+			t.Fatalf("color %d above alpha %d", r, a)
+		}
+		if a > 0 && int(r)*255/int(a) < 250 {
+			t.Fatalf("edge tinted: r=%d a=%d", r, a)
+		}
+	}
+}
+
+func TestResampleParallelMatchesSerial(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1200, 900))
+	rand.New(rand.NewSource(1)).Read(src.Pix)
+	f := imageResampleFilter()
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	parallel := resample(src, 700, 525, f)
+	runtime.GOMAXPROCS(1)
+	serial := resample(src, 700, 525, f)
+	if !bytes.Equal(parallel.Pix, serial.Pix) {
+		t.Error("parallel and serial results differ")
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------- Blog post revisions ----------------
+// Every saved state of a post is kept as a numbered snapshot under
+// <data>/revisions/<id>/NNNN.json. The hero image of each snapshot is stored
+// once per distinct content as img-<hash>.png in the same directory, so a bad
+// edit or image replacement can always be rolled back.
+
+// postRevision is one saved state of a post
+type postRevision struct {
+	Rev       int        `json:"rev"`
+	Time      time.Time  `json:"time"`
+	Author    string     `json:"author,omitempty"`
+	Action    string     `json:"action"` // baseline, create, edit, status, restore
+	ImageHash string     `json:"image_hash,omitempty"`
+	Post      postRecord `json:"post"`
+}
+
+type revisionStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+var revisions = revisionStore{dir: revisionsPath()}
+
+var reRevisionFile = regexp.MustCompile(`^(\d{4,})\.json$`)
+
+func revisionsPath() string {
+	if p := os.Getenv("REVISIONS_PATH"); p != "" {
+		return p
+	}
+	// Default: next to club.json on the data volume
+	return filepath.Join(filepath.Dir(dataPath()), "revisions")
+}
+
+func (rs *revisionStore) postDir(id string) string {
+	return filepath.Join(rs.dir, id)
+}
+
+// ensureBaseline snapshots the current state of a post that has no revisions
+// yet (posts created before revisions existed), so its first edit can be undone.
+// Call it before the post or its image is modified.
+func (rs *revisionStore) ensureBaseline(p postRecord, imgPath string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	revs, err := rs.revNumbersLocked(p.ID)
+	if err != nil || len(revs) > 0 {
+		return err
+	}
+	_, err = rs.recordLocked(p, imgPath, "", "baseline")
+	return err
+}
+
+// record stores p (and the image at imgPath, if any) as the newest revision
+func (rs *revisionStore) record(p postRecord, imgPath, author, action string) (postRevision, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.recordLocked(p, imgPath, author, action)
+}
+
+func (rs *revisionStore) recordLocked(p postRecord, imgPath, author, action string) (postRevision, error) {
+	revs, err := rs.revNumbersLocked(p.ID)
+	if err != nil {
+		return postRevision{}, err
+	}
+	next := 1
+	if len(revs) > 0 {
+		next = revs[len(revs)-1] + 1
+	}
+	rev := postRevision{Rev: next, Time: time.Now(), Author: author, Action: action, Post: p}
+	if imgPath != "" {
+		hash, err := rs.storeImageLocked(p.ID, imgPath)
+		if err != nil && !os.IsNotExist(err) {
+			return postRevision{}, err
+		}
+		rev.ImageHash = hash
+	}
+	b, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return postRevision{}, fmt.Errorf("marshal: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(rs.postDir(p.ID), fmt.Sprintf("%04d.json", next)), b); err != nil {
+		return postRevision{}, err
+	}
+	return rev, nil
+}
+
+// storeImageLocked copies the image into the revision directory unless a copy
+// with the same content is already there, and returns its content hash
+func (rs *revisionStore) storeImageLocked(id, imgPath string) (string, error) {
+	b, err := os.ReadFile(imgPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	hash := hex.EncodeToString(sum[:8])
+	dest := filepath.Join(rs.postDir(id), "img-"+hash+".png")
+	if _, err := os.Stat(dest); err == nil {
+		return hash, nil
+	}
+	return hash, writeFileAtomic(dest, b)
+}
+
+// revNumbersLocked returns the revision numbers of a post in ascending order
+func (rs *revisionStore) revNumbersLocked(id string) ([]int, error) {
+	entries, err := os.ReadDir(rs.postDir(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("readdir revisions: %w", err)
+	}
+	var revs []int
+	for _, e := range entries {
+		if m := reRevisionFile.FindStringSubmatch(e.Name()); m != nil {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				revs = append(revs, n)
+			}
+		}
+	}
+	sort.Ints(revs)
+	return revs, nil
+}
+
+// list returns all revisions of a post, oldest first
+func (rs *revisionStore) list(id string) ([]postRevision, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	revs, err := rs.revNumbersLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]postRevision, 0, len(revs))
+	for _, n := range revs {
+		rev, err := rs.readLocked(id, n)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rev)
+	}
+	return out, nil
+}
+
+// get returns a single revision; rev 0 means the newest one
+func (rs *revisionStore) get(id string, rev int) (postRevision, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rev == 0 {
+		revs, err := rs.revNumbersLocked(id)
+		if err != nil {
+			return postRevision{}, err
+		}
+		if len(revs) == 0 {
+			return postRevision{}, os.ErrNotExist
+		}
+		rev = revs[len(revs)-1]
+	}
+	return rs.readLocked(id, rev)
+}
+
+func (rs *revisionStore) readLocked(id string, n int) (postRevision, error) {
+	b, err := os.ReadFile(filepath.Join(rs.postDir(id), fmt.Sprintf("%04d.json", n)))
+	if err != nil {
+		return postRevision{}, err
+	}
+	var rev postRevision
+	if err := json.Unmarshal(b, &rev); err != nil {
+		return postRevision{}, fmt.Errorf("unmarshal revision %s/%d: %w", id, n, err)
+	}
+	return rev, nil
+}
+
+// stagedImage is the image of a revision with its variants, written to a
+// temporary directory next to the live image until the restored record is
+// stored
+type stagedImage struct {
+	dir     string // empty: the revision had no image
+	sources []imageSource
+}
+
+// stageImage writes the image of rev and its variants, named as at imgPath,
+// into a temporary directory beside imgPath
+func (rs *revisionStore) stageImage(rev postRevision, imgPath string) (stagedImage, error) {
+	if rev.ImageHash == "" {
+		return stagedImage{}, nil
+	}
+	b, err := os.ReadFile(filepath.Join(rs.postDir(rev.Post.ID), "img-"+rev.ImageHash+".png"))
+	if err != nil {
+		return stagedImage{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(imgPath), 0755); err != nil {
+		return stagedImage{}, err
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(imgPath), ".restore-")
+	if err != nil {
+		return stagedImage{}, err
+	}
+	st := stagedImage{dir: dir}
+	staged := filepath.Join(dir, filepath.Base(imgPath))
+	if err := os.WriteFile(staged, b, 0644); err != nil {
+		st.discard()
+		return stagedImage{}, err
+	}
+	if st.sources, err = imageVariantsFromPNG(staged); err != nil {
+		log.Printf("warn: restore %s image variants: %v", rev.Post.ID, err)
+	}
+	return st, nil
+}
+
+// commit replaces the image at imgPath and its variants by the staged ones;
+// without a staged image the current one is removed
+func (st stagedImage) commit(imgPath string) error {
+	removeImageVariants(imgPath)
+	if st.dir == "" {
+		if err := os.Remove(imgPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	defer st.discard()
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return err
+	}
+	move := func(name string) error {
+		return os.Rename(filepath.Join(st.dir, name), filepath.Join(filepath.Dir(imgPath), name))
+	}
+	for _, e := range entries {
+		if e.Name() != filepath.Base(imgPath) {
+			if err := move(e.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	// The image itself last, so it never sits beside variants of another one
+	return move(filepath.Base(imgPath))
+}
+
+// discard removes the staging directory
+func (st stagedImage) discard() {
+	if st.dir != "" {
+		_ = os.RemoveAll(st.dir)
+	}
+}
+
+// revisionRestoreHandler brings a post back to one of its revisions (POST id,
+// rev). Its single page is rendered again, which the numeric and the slug URL
+// both serve. The record and the image change together: the revision's image
+// is staged beside the live one and moved into place only once the record is
+// stored, and restoring a revision without an image removes the current one.
+func revisionRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, roleEditor) {
+		return
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimSpace(r.FormValue("id"))
+	cur, ok := posts.get(id)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	n, err := strconv.Atoi(r.FormValue("rev"))
+	if err != nil || n <= 0 {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+	rev, err := revisions.get(id, n)
+	if err != nil {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+	p := rev.Post
+	p.Status = cur.Status
+	p.PublishAt = cur.PublishAt
+	p.UpdatedBy = requestUser(r)
+	if posts.slugTaken(p.Slug, id) {
+		http.Error(w, "the slug of this revision now belongs to another post", http.StatusConflict)
+		return
+	}
+
+	imgPath := filepath.Join(staticPath(), "img", "blog", id+".png")
+	if err := revisions.ensureBaseline(cur, imgPath); err != nil {
+		log.Printf("warn: blog revision baseline %s: %v", id, err)
+	}
+	staged, err := revisions.stageImage(rev, imgPath)
+	if err != nil {
+		log.Printf("blog restore %s image: %v", id, err)
+		http.Error(w, "cannot restore image", http.StatusInternalServerError)
+		return
+	}
+	p.ImageSources = staged.sources
+	if staged.dir == "" {
+		p.ImageFit, p.ImageMeta = nil, nil
+	}
+	saved, err := posts.put(p)
+	if err != nil {
+		staged.discard()
+		log.Printf("blog restore %s: %v", id, err)
+		http.Error(w, "cannot write", http.StatusInternalServerError)
+		return
+	}
+	if err := staged.commit(imgPath); err != nil {
+		log.Printf("blog restore %s image: %v", id, err)
+		http.Error(w, "cannot restore image", http.StatusInternalServerError)
+		return
+	}
+	newRev, err := revisions.record(saved, imgPath, requestUser(r), "restore")
+	if err != nil {
+		log.Printf("warn: blog revision %s: %v", id, err)
+	}
+	audit.record(r, auditPostRestore, fmt.Sprintf("revision %d", rev.Rev), id)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "restored": rev.Rev, "rev": newRev.Rev})
+}
+
+// ---- Revision diff ----
+
+// fieldChange describes a metadata field that differs between two revisions
+type fieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// diffLine is one line of a body diff: op is "=", "-" or "+"
+type diffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// diffRevisions compares the metadata and body of two revisions
+func diffRevisions(a, b postRevision) (map[string]fieldChange, []diffLine) {
+	fields := make(map[string]fieldChange)
+	cmp := func(name string, x, y any) {
+		if fmt.Sprint(x) != fmt.Sprint(y) {
+			fields[name] = fieldChange{From: x, To: y}
+		}
+	}
+	cmp("title", a.Post.Title, b.Post.Title)
+	cmp("slug", a.Post.Slug, b.Post.Slug)
+	cmp("annotation", a.Post.Annotation, b.Post.Annotation)
+	cmp("categories", a.Post.Categories, b.Post.Categories)
+	cmp("content_mode", a.Post.ContentMode, b.Post.ContentMode)
+	cmp("status", a.Post.Status, b.Post.Status)
+	cmp("image", a.ImageHash, b.ImageHash)
+	return fields, diffLines(revisionLines(a.Post), revisionLines(b.Post))
+}
+
+// revisionLines returns the body of p as diff lines: the source of Markdown
+// posts, the HTML of the others
+func revisionLines(p postRecord) []string {
+	if p.ContentMode == contentMarkdown {
+		var lines []string
+		for _, l := range strings.Split(p.Markdown, "\n") {
+			if l = strings.TrimRight(l, " \t"); l != "" {
+				lines = append(lines, l)
+			}
+		}
+		return lines
+	}
+	return splitHTMLLines(p.Body)
+}
+
+var reBlockEnd = regexp.MustCompile(`(?i)(</(p|h[1-6]|li|ul|ol|div|blockquote|figure|pre|table|tr)>|<br\s*/?>)`)
+
+// splitHTMLLines breaks body HTML after block-level closing tags so editor
+// output, which is often a single line, diffs paragraph by paragraph
+func splitHTMLLines(body string) []string {
+	var lines []string
+	for _, l := range strings.Split(reBlockEnd.ReplaceAllString(body, "$1\n"), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// diffLines computes a line diff via longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var out []diffLine
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{Op: "=", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{Op: "-", Text: a[i]})
+			i++
+		default:
+			out = append(out, diffLine{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out = append(out, diffLine{Op: "-", Text: a[i]})
+	}
+	for ; j < m; j++ {
+		out = append(out, diffLine{Op: "+", Text: b[j]})
+	}
+	return out
+}
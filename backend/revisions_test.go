@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestRevisionsRecordDiffRestore verifies edits are kept as revisions with
+// their images and an older revision can be diffed and brought back.
+func TestRevisionsRecordDiffRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	rs := revisionStore{dir: filepath.Join(tmpDir, "revisions")}
+	img := filepath.Join(tmpDir, "0001.png")
+	os.WriteFile(img, []byte("old image"), 0644)
+
+	p := postRecord{ID: "0001", Slug: "zapas", Title: "Zápas", Body: "<p>Úvod</p><p>Konec</p>", Status: postPublished}
+	if err := rs.ensureBaseline(p, img); err != nil {
+		t.Fatal(err)
+	}
+	// A second baseline call must not add another revision
+	if err := rs.ensureBaseline(p, img); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(img, []byte("new image"), 0644)
+	p.Title = "Zápas 3:2"
+	p.Body = "<p>Úvod</p><p>Nový konec</p>"
+	if _, err := rs.record(p, img, "admin", "edit"); err != nil {
+		t.Fatal(err)
+	}
+
+	revs, err := rs.list("0001")
+	if err != nil || len(revs) != 2 {
+		t.Fatalf("expected 2 revisions, got %d (%v)", len(revs), err)
+	}
+	if revs[0].Action != "baseline" || revs[1].Author != "admin" || revs[0].ImageHash == revs[1].ImageHash {
+		t.Fatalf("unexpected revisions: %+v", revs)
+	}
+
+	fields, body := diffRevisions(revs[0], revs[1])
+	if _, ok := fields["title"]; !ok {
+		t.Error("title change not reported")
+	}
+	if _, ok := fields["image"]; !ok {
+		t.Error("image change not reported")
+	}
+	want := []diffLine{{"=", "<p>Úvod</p>"}, {"-", "<p>Konec</p>"}, {"+", "<p>Nový konec</p>"}}
+	if len(body) != len(want) {
+		t.Fatalf("unexpected body diff: %+v", body)
+	}
+	for i := range want {
+		if body[i] != want[i] {
+			t.Errorf("diff line %d: got %+v, want %+v", i, body[i], want[i])
+		}
+	}
+
+	first, err := rs.get("0001", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staged, err := rs.stageImage(first, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(img); string(b) != "new image" {
+		t.Errorf("live image replaced before commit: %q", b)
+	}
+	if err := staged.commit(img); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(img); string(b) != "old image" {
+		t.Errorf("image not restored: %q", b)
+	}
+	if newest, err := rs.get("0001", 0); err != nil || newest.Rev != 2 {
+		t.Errorf("newest revision: %+v, %v", newest, err)
+	}
+}
+
+// TestRevisionRestoreHandler restores a post through the API: the page the
+// numeric and the slug URL serve is rewritten, the image and record change
+// together, and a failed restore leaves both alone.
+func TestRevisionRestoreHandler(t *testing.T) {
+	site := t.TempDir()
+	blogDir := filepath.Join(site, "blog")
+	imgPath := filepath.Join(site, "img", "blog", "0001.png")
+	os.MkdirAll(blogDir, 0755)
+	os.MkdirAll(filepath.Dir(imgPath), 0755)
+	t.Setenv("STATIC_PATH", site)
+	t.Setenv("AUDIT_PATH", filepath.Join(site, "audit.jsonl"))
+	if err := posts.open(blogDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer posts.open(t.TempDir(), "")
+	savedRevisions := revisions.dir
+	revisions.dir = filepath.Join(site, "revisions")
+	defer func() { revisions.dir = savedRevisions }()
+	if err := users.open(filepath.Join(t.TempDir(), "users.json")); err != nil {
+		t.Fatal(err)
+	}
+	defer users.open(filepath.Join(t.TempDir(), "none.json"))
+	users.set("redaktor", roleEditor, "heslo-redaktora", true)
+	t.Setenv("ADMIN_BASIC_AUTH", "1")
+
+	save := func(p postRecord, img []byte) {
+		t.Helper()
+		if img != nil {
+			os.WriteFile(imgPath, img, 0644)
+		}
+		if _, err := posts.put(p); err != nil {
+			t.Fatal(err)
+		}
+		path := imgPath
+		if img == nil {
+			path = ""
+		}
+		if _, err := revisions.record(p, path, "redaktor", "edit"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	restore := func(rev string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/blog/revisions/restore", strings.NewReader(url.Values{"id": {"0001"}, "rev": {rev}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("redaktor", "heslo-redaktora")
+		rec := httptest.NewRecorder()
+		revisionRestoreHandler(rec, req)
+		return rec
+	}
+	red, blue := encodePNG(t, halves(1600, 969)).Bytes(), encodePNG(t, image.NewRGBA(image.Rect(0, 0, 1600, 969))).Bytes()
+	first := postRecord{ID: "0001", Slug: "zapas", Title: "Zápas", Body: "<p>První verze</p>", Status: postPublished, Image: "/img/blog/0001.png"}
+	save(first, red)
+	second := first
+	second.Slug, second.Title, second.Body = "zapas-3-2", "Zápas 3:2", "<p>Druhá verze</p>"
+	second.ImageFit = &imageFit{Mode: fitCover, FocusX: 0.5, FocusY: 0.5}
+	save(second, blue)
+
+	if rec := restore("1"); rec.Code != http.StatusOK {
+		t.Fatalf("restore: %d %s", rec.Code, rec.Body)
+	}
+	page, _ := os.ReadFile(filepath.Join(blogDir, "0001.html"))
+	if !strings.Contains(string(page), "První verze") || !strings.Contains(string(page), `<meta name="slug" content="zapas">`) {
+		t.Errorf("page not rewritten:\n%s", page)
+	}
+	// Both URLs lead to that page: the slug resolves to it and the later slug
+	// redirects; no separate slug copy is left behind
+	if id, ok := posts.lookupSlug("zapas"); !ok || id != "0001" {
+		t.Error("restored slug not served")
+	}
+	if id, ok := posts.lookupAlias("zapas-3-2"); !ok || id != "0001" {
+		t.Error("later slug does not redirect")
+	}
+	for _, name := range []string{"zapas.html", "zapas-3-2.html"} {
+		if _, err := os.Stat(filepath.Join(blogDir, name)); !os.IsNotExist(err) {
+			t.Errorf("slug copy %s written", name)
+		}
+	}
+	if b, _ := os.ReadFile(imgPath); !bytes.Equal(b, red) {
+		t.Error("image not restored")
+	}
+	if p, _ := posts.get("0001"); p.ImageFit != nil || len(p.ImageSources) == 0 {
+		t.Errorf("restored record: fit %+v, sources %v", p.ImageFit, p.ImageSources)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(imgPath), "0001-400.jpg")); err != nil {
+		t.Errorf("variants not restored: %v", err)
+	}
+
+	// The slug of revision 2 now belongs to another post: nothing changes
+	posts.removeAlias("0001", "zapas-3-2")
+	if _, err := posts.put(postRecord{ID: "0002", Slug: "zapas-3-2", Title: "Jiný", Body: "<p>x</p>", Status: postPublished}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := restore("2"); rec.Code != http.StatusConflict {
+		t.Errorf("restore onto a taken slug: %d", rec.Code)
+	}
+	if b, _ := os.ReadFile(imgPath); !bytes.Equal(b, red) {
+		t.Error("image replaced by a failed restore")
+	}
+	if p, _ := posts.get("0001"); p.Slug != "zapas" {
+		t.Errorf("record changed by a failed restore: %s", p.Slug)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(imgPath))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".restore-") {
+			t.Errorf("staging directory %s left behind", e.Name())
+		}
+	}
+
+	// A revision without an image removes the current one
+	third := first
+	third.Body = "<p>Bez obrázku</p>"
+	save(third, nil)
+	revs, _ := revisions.list("0001")
+	if rec := restore(strconv.Itoa(revs[len(revs)-1].Rev)); rec.Code != http.StatusOK {
+		t.Fatalf("restore without image: %d %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(imgPath); !os.IsNotExist(err) {
+		t.Error("image of a revision without one kept")
+	}
+	if p, _ := posts.get("0001"); len(p.ImageSources) != 0 {
+		t.Errorf("sources of a removed image: %v", p.ImageSources)
+	}
+}
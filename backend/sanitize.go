@@ -0,0 +1,274 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ---------------- Post HTML sanitizer ----------------
+// Post bodies come from the Quill editor, the HTML editor and imported
+// WordPress pages. Only the markup those produce is kept: text formatting,
+// headings, lists, quotes, code, tables, links, images and YouTube embeds.
+// Scripts, styles, forms, event handlers and javascript: URLs are dropped;
+// unknown elements are removed but their text is kept.
+
+// sanitizePolicyVersion is part of the layout hash; bump it when the policy
+// changes so stored pages are rendered again
+const sanitizePolicyVersion = "1"
+
+// sanitizeAllowed maps each allowed element to its allowed attributes besides
+// the global class and style
+var sanitizeAllowed = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil, atom.Div: nil, atom.Span: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.Strong: nil, atom.B: nil, atom.Em: nil, atom.I: nil, atom.U: nil, atom.S: nil,
+	atom.Strike: nil, atom.Del: nil, atom.Ins: nil, atom.Sub: nil, atom.Sup: nil, atom.Small: nil, atom.Mark: nil,
+	atom.Blockquote: {"cite"}, atom.Pre: nil, atom.Code: nil,
+	atom.Ul: nil, atom.Ol: {"start", "reversed"}, atom.Li: nil,
+	atom.Figure: nil, atom.Figcaption: nil,
+	atom.Table: nil, atom.Caption: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil, atom.Tr: nil,
+	atom.Th: {"colspan", "rowspan", "scope"}, atom.Td: {"colspan", "rowspan"},
+	atom.A:      {"href", "title", "target", "rel"},
+	atom.Img:    {"src", "alt", "title", "width", "height", "srcset", "sizes", "loading", "decoding"},
+	atom.Iframe: {"src", "width", "height", "title", "allow", "allowfullscreen", "frameborder"},
+}
+
+// sanitizeDropContent lists elements removed together with everything inside
+var sanitizeDropContent = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Object: true, atom.Embed: true, atom.Applet: true, atom.Frameset: true, atom.Frame: true,
+	atom.Svg: true, atom.Math: true, atom.Form: true, atom.Select: true, atom.Textarea: true,
+	atom.Button: true, atom.Head: true, atom.Title: true,
+}
+
+var (
+	reYouTubeEmbed = regexp.MustCompile(`^https://(www\.)?(youtube\.com|youtube-nocookie\.com)/embed/[A-Za-z0-9_-]{6,20}(\?[A-Za-z0-9_=&;.-]*)?$`)
+	reDataImage    = regexp.MustCompile(`^data:image/(png|jpeg|gif|webp);base64,[A-Za-z0-9+/=\s]+$`)
+	reNumber       = regexp.MustCompile(`^\d{1,5}%?$`)
+	reCSSValue     = regexp.MustCompile(`^[#a-zA-Z0-9 ,.%()-]{1,64}$`)
+)
+
+// sanitizeStyleProps are the CSS properties Quill and the imported posts use
+var sanitizeStyleProps = map[string]bool{
+	"color": true, "background-color": true, "text-align": true,
+	"font-weight": true, "font-style": true, "text-decoration": true,
+}
+
+// safeURL reports whether u may be used as a link or image address
+func safeURL(u string, image bool) bool {
+	u = strings.TrimSpace(u)
+	if image && reDataImage.MatchString(u) {
+		return true
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "":
+		// Relative address; "//host" keeps the page's scheme, which is fine
+		return !strings.ContainsAny(u, "\x00")
+	case "http", "https":
+		return true
+	case "mailto", "tel":
+		return !image
+	}
+	return false
+}
+
+// sanitizeStyle keeps the allowed declarations of a style attribute
+func sanitizeStyle(style string) string {
+	var kept []string
+	for _, decl := range strings.Split(style, ";") {
+		name, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		lower := strings.ToLower(value)
+		if !sanitizeStyleProps[name] || !reCSSValue.MatchString(value) ||
+			strings.Contains(lower, "url") || strings.Contains(lower, "expression") {
+			continue
+		}
+		kept = append(kept, name+": "+value)
+	}
+	return strings.Join(kept, "; ")
+}
+
+// sanitizeAttrs filters the attributes of an allowed element; ok is false if
+// the element itself must go (an iframe that is not a YouTube embed)
+func sanitizeAttrs(tag atom.Atom, attrs []html.Attribute) ([]html.Attribute, bool) {
+	allowed := sanitizeAllowed[tag]
+	var out []html.Attribute
+	blank := false
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" {
+			continue
+		}
+		switch {
+		case key == "class":
+			if v := strings.TrimSpace(a.Val); v != "" {
+				out = append(out, html.Attribute{Key: key, Val: v})
+			}
+			continue
+		case key == "style":
+			if v := sanitizeStyle(a.Val); v != "" {
+				out = append(out, html.Attribute{Key: key, Val: v})
+			}
+			continue
+		}
+		found := false
+		for _, k := range allowed {
+			found = found || k == key
+		}
+		if !found {
+			continue
+		}
+		switch key {
+		case "href":
+			if !safeURL(a.Val, false) {
+				continue
+			}
+		case "src":
+			if tag == atom.Iframe && !reYouTubeEmbed.MatchString(strings.TrimSpace(a.Val)) {
+				return nil, false
+			}
+			if tag == atom.Img && !safeURL(a.Val, true) {
+				continue
+			}
+		case "srcset":
+			ok := true
+			for _, part := range strings.Split(a.Val, ",") {
+				if f := strings.Fields(part); len(f) > 0 && !safeURL(f[0], false) {
+					ok = false
+				}
+			}
+			if !ok {
+				continue
+			}
+		case "width", "height", "colspan", "rowspan", "start", "frameborder":
+			if !reNumber.MatchString(strings.TrimSpace(a.Val)) {
+				continue
+			}
+		case "target":
+			if a.Val != "_blank" {
+				continue
+			}
+			blank = true
+		case "rel":
+			// Set below for target=_blank; other values are harmless hints
+		}
+		out = append(out, html.Attribute{Key: key, Val: a.Val})
+	}
+	if tag == atom.Iframe {
+		hasSrc := false
+		for _, a := range out {
+			hasSrc = hasSrc || a.Key == "src"
+		}
+		if !hasSrc {
+			return nil, false
+		}
+	}
+	if blank {
+		for i := range out {
+			if out[i].Key == "rel" {
+				out = append(out[:i], out[i+1:]...)
+				break
+			}
+		}
+		out = append(out, html.Attribute{Key: "rel", Val: "noopener noreferrer"})
+	}
+	return out, true
+}
+
+// sanitizeHTML returns the parts of a post body the policy allows
+func sanitizeHTML(s string) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+	var b strings.Builder
+	var open []atom.Atom // allowed elements written and not yet closed
+	skip := 0            // depth inside a dropped element
+	var skipTag atom.Atom
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if skip > 0 {
+				if tok.DataAtom == skipTag && tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if sanitizeDropContent[tok.DataAtom] {
+				if tt == html.StartTagToken {
+					skip, skipTag = 1, tok.DataAtom
+				}
+				continue
+			}
+			if _, ok := sanitizeAllowed[tok.DataAtom]; !ok {
+				continue
+			}
+			attrs, ok := sanitizeAttrs(tok.DataAtom, tok.Attr)
+			if !ok {
+				// A foreign iframe: drop it with its fallback content
+				if tt == html.StartTagToken {
+					skip, skipTag = 1, tok.DataAtom
+				}
+				continue
+			}
+			tok.Attr = attrs
+			tok.Type = html.StartTagToken
+			b.WriteString(tok.String())
+			switch {
+			case voidElement(tok.DataAtom):
+			case tt == html.SelfClosingTagToken:
+				b.WriteString("</" + tok.Data + ">")
+			default:
+				open = append(open, tok.DataAtom)
+			}
+		case html.EndTagToken:
+			if skip > 0 {
+				if tok.DataAtom == skipTag {
+					skip--
+				}
+				continue
+			}
+			// Close only elements that are open, closing any left inside
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == tok.DataAtom {
+					for j := len(open) - 1; j >= i; j-- {
+						b.WriteString("</" + open[j].String() + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+	for j := len(open) - 1; j >= 0; j-- {
+		b.WriteString("</" + open[j].String() + ">")
+	}
+	return b.String()
+}
+
+// voidElement reports whether an element has no end tag
+func voidElement(a atom.Atom) bool {
+	switch a {
+	case atom.Br, atom.Hr, atom.Img:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	cases := []struct{ name, in, want string }{
+		{"quill formatting",
+			`<h2>Zápas</h2><p><strong>Výhra</strong> <span style="color: rgb(230, 0, 0);">3:1</span></p><ol><li>Novák</li></ol>`,
+			`<h2>Zápas</h2><p><strong>Výhra</strong> <span style="color: rgb(230, 0, 0)">3:1</span></p><ol><li>Novák</li></ol>`},
+		{"imported figure",
+			`<figure class="wp-block-image"><img src="/img/a.jpg" alt="Tým" width="800" height="600" loading="lazy" data-id="5"></figure>`,
+			`<figure class="wp-block-image"><img src="/img/a.jpg" alt="Tým" width="800" height="600" loading="lazy"></figure>`},
+		{"script dropped with content",
+			`<p>a</p><script>alert(1)</script><p>b</p>`,
+			`<p>a</p><p>b</p>`},
+		{"event handlers and javascript urls",
+			`<a href="javascript:alert(1)" onclick="x()">odkaz</a><img src="x" onerror="alert(1)">`,
+			`<a>odkaz</a><img src="x">`},
+		{"blank target gets noopener",
+			`<a href="https://fotbal.cz" target="_blank" rel="opener">FAČR</a>`,
+			`<a href="https://fotbal.cz" target="_blank" rel="noopener noreferrer">FAČR</a>`},
+		{"youtube embed kept, other iframes dropped",
+			`<iframe src="https://www.youtube.com/embed/dQw4w9WgXcQ" width="560" height="315" allowfullscreen></iframe><iframe src="https://evil.example/"><p>x</p></iframe>`,
+			`<iframe src="https://www.youtube.com/embed/dQw4w9WgXcQ" width="560" height="315" allowfullscreen=""></iframe>`},
+		{"unknown tags unwrapped, styles filtered",
+			`<font color="red"><p style="text-align: center; background: url(x); position: fixed">Text</p></font>`,
+			`<p style="text-align: center">Text</p>`},
+		{"unclosed elements closed",
+			`<p><em>konec`,
+			`<p><em>konec</em></p>`},
+		{"text is escaped",
+			`1 &lt; 2 &amp; <b>3</b>`,
+			`1 &lt; 2 &amp; <b>3</b>`},
+	}
+	for _, tc := range cases {
+		if got := sanitizeHTML(tc.in); got != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	t.Setenv("ANALYTICS_ORIGIN", "https://stats.example")
+	rec := httptest.NewRecorder()
+	loadSecurityPolicy().wrap(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	csp := rec.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "frame-ancestors 'self'") || !strings.Contains(csp, "https://stats.example") ||
+		strings.Contains(csp, defaultAnalyticsOrigin) {
+		t.Errorf("CSP: %s", csp)
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" || rec.Header().Get("Referrer-Policy") == "" {
+		t.Errorf("headers: %v", rec.Header())
+	}
+}
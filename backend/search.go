@@ -0,0 +1,323 @@
+package main
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ---------------- Blog search ----------------
+// An in-memory inverted index over published posts: every word of a post's
+// title, annotation and body text (tags stripped) is folded with foldCzech and
+// maps to the posts containing it, so "zapas" finds "Zápas". The terms are
+// also kept sorted, so prefix matches are a binary search away. The post store
+// updates the index for each post it stores, publishes or removes.
+
+// Term weights per field; a title match outranks several body matches
+const (
+	searchWeightTitle      = 5
+	searchWeightAnnotation = 2
+	searchWeightBody       = 1
+)
+
+// Snippet window around the first match, in runes
+const (
+	snippetBefore = 60
+	snippetLength = 200
+)
+
+type searchDoc struct {
+	text       string         // plain body text, for snippets
+	annotation string         // fallback snippet source
+	terms      map[string]int // folded term -> weighted frequency
+}
+
+type searchIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]*searchDoc
+	postings map[string]map[string]int // term -> post ID -> weighted frequency
+	terms    []string                  // keys of postings, sorted
+}
+
+// searchHit is a ranked match of a query
+type searchHit struct {
+	ID      string
+	Score   float64
+	Snippet string
+}
+
+var (
+	reScriptStyle = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>`)
+	reHTMLTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// plainText strips markup and shortcodes from post HTML and collapses whitespace
+func plainText(s string) string {
+	s = stripShortcodes(s)
+	s = reScriptStyle.ReplaceAllString(s, " ")
+	s = reHTMLTag.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// scanWords calls fn with the byte range of every run of letters and digits in s
+func scanWords(s string, fn func(start, end int)) {
+	start := -1
+	for i, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			fn(start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		fn(start, len(s))
+	}
+}
+
+// searchTerms returns the folded words of s
+func searchTerms(s string) []string {
+	var terms []string
+	scanWords(s, func(a, b int) {
+		terms = append(terms, foldCzech(s[a:b]))
+	})
+	return terms
+}
+
+// termWeight tells how well an indexed term matches a query term: exactly,
+// or as a longer form of it ("zapas" also finds "zapasu", "zapasy")
+func termWeight(term, query string) float64 {
+	switch {
+	case term == query:
+		return 1
+	case len(query) >= 3 && strings.HasPrefix(term, query):
+		return 0.5
+	}
+	return 0
+}
+
+// reset empties the index
+func (ix *searchIndex) reset() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs = make(map[string]*searchDoc)
+	ix.postings = make(map[string]map[string]int)
+	ix.terms = nil
+}
+
+// update (re)indexes p; posts that are not published are dropped
+func (ix *searchIndex) update(p *postRecord) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.docs == nil {
+		ix.docs = make(map[string]*searchDoc)
+		ix.postings = make(map[string]map[string]int)
+	}
+	ix.removeLocked(p.ID)
+	if p.Status != postPublished {
+		return
+	}
+	doc := &searchDoc{text: plainText(p.Body), annotation: p.Annotation, terms: make(map[string]int)}
+	for _, f := range []struct {
+		text   string
+		weight int
+	}{{p.Title, searchWeightTitle}, {p.Annotation, searchWeightAnnotation}, {doc.text, searchWeightBody}} {
+		for _, t := range searchTerms(f.text) {
+			doc.terms[t] += f.weight
+		}
+	}
+	ix.docs[p.ID] = doc
+	for t, n := range doc.terms {
+		ids := ix.postings[t]
+		if ids == nil {
+			ids = make(map[string]int)
+			ix.postings[t] = ids
+			i := sort.SearchStrings(ix.terms, t)
+			ix.terms = append(ix.terms, "")
+			copy(ix.terms[i+1:], ix.terms[i:])
+			ix.terms[i] = t
+		}
+		ids[p.ID] = n
+	}
+}
+
+// remove drops a post from the index
+func (ix *searchIndex) remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(id)
+}
+
+func (ix *searchIndex) removeLocked(id string) {
+	doc := ix.docs[id]
+	if doc == nil {
+		return
+	}
+	for t := range doc.terms {
+		delete(ix.postings[t], id)
+		if len(ix.postings[t]) == 0 {
+			delete(ix.postings, t)
+			i := sort.SearchStrings(ix.terms, t)
+			ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// search returns up to limit posts containing every word of query (0 = all),
+// best match first, and the total number of matching posts
+func (ix *searchIndex) search(query string, limit int) ([]searchHit, int) {
+	var qterms []string
+	seen := make(map[string]bool)
+	for _, t := range searchTerms(query) {
+		if !seen[t] {
+			seen[t] = true
+			qterms = append(qterms, t)
+		}
+	}
+	if len(qterms) == 0 {
+		return nil, 0
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	n := float64(len(ix.docs))
+	var scores map[string]float64
+	for i, qt := range qterms {
+		hits := make(map[string]float64)
+		for _, term := range ix.matchingTermsLocked(qt) {
+			ids := ix.postings[term]
+			w := termWeight(term, qt)
+			idf := math.Log(1 + n/float64(len(ids)))
+			for id, freq := range ids {
+				hits[id] += w * idf * (1 + math.Log(float64(freq)))
+			}
+		}
+		if i == 0 {
+			scores = hits
+			continue
+		}
+		for id := range scores {
+			if h, ok := hits[id]; ok {
+				scores[id] += h
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+	out := make([]searchHit, 0, len(scores))
+	for id, sc := range scores {
+		out = append(out, searchHit{ID: id, Score: math.Round(sc*1000) / 1000})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].ID > out[j].ID
+	})
+	total := len(out)
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	for i := range out {
+		doc := ix.docs[out[i].ID]
+		s, ok := snippet(doc.text, qterms)
+		if !ok && doc.annotation != "" {
+			s, _ = snippet(doc.annotation, qterms)
+		}
+		out[i].Snippet = s
+	}
+	return out, total
+}
+
+// matchingTermsLocked returns the indexed terms a query term finds (see
+// termWeight): itself and, from three letters on, the terms it starts
+func (ix *searchIndex) matchingTermsLocked(query string) []string {
+	if len(query) < 3 {
+		if ix.postings[query] != nil {
+			return []string{query}
+		}
+		return nil
+	}
+	i := sort.SearchStrings(ix.terms, query)
+	j := i
+	for j < len(ix.terms) && strings.HasPrefix(ix.terms[j], query) {
+		j++
+	}
+	return ix.terms[i:j]
+}
+
+// snippet returns an HTML-escaped excerpt of text around the first word
+// matching qterms, with matching words wrapped in <mark>. Without a match it
+// returns the beginning of text and false.
+func snippet(text string, qterms []string) (string, bool) {
+	type span struct{ start, end int }
+	var marks []span
+	scanWords(text, func(a, b int) {
+		folded := foldCzech(text[a:b])
+		for _, qt := range qterms {
+			if termWeight(folded, qt) > 0 {
+				marks = append(marks, span{a, b})
+				return
+			}
+		}
+	})
+	start := 0
+	if len(marks) > 0 {
+		start = moveRunes(text, marks[0].start, -snippetBefore)
+	}
+	end := moveRunes(text, start, snippetLength)
+	// Do not cut words in half
+	if start > 0 {
+		if i := strings.IndexByte(text[start:], ' '); i >= 0 && start+i < marks[0].start {
+			start += i + 1
+		}
+	}
+	if end < len(text) {
+		if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+			end = start + i
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range marks {
+		if m.start < start || m.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), len(marks) > 0
+}
+
+// moveRunes returns the byte offset n runes after (or, if n < 0, before) i
+func moveRunes(s string, i, n int) int {
+	for ; n > 0 && i < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	for ; n < 0 && i > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+	}
+	return i
+}
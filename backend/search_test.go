@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestSearchIndex verifies Czech folding, ranking, snippets and incremental
+// updates of the blog search index.
+func TestSearchIndex(t *testing.T) {
+	var ix searchIndex
+	ix.update(&postRecord{ID: "0001", Title: "Zápas s Hradištěm", Body: "<p>Bizoni vyhráli <b>zápas</b> 3:2.</p>", Status: postPublished})
+	ix.update(&postRecord{ID: "0002", Title: "Trénink", Body: "<p>Po zápasu jsme trénovali &amp; odpočívali.</p>", Status: postPublished})
+	ix.update(&postRecord{ID: "0003", Title: "Koncept zápasu", Status: postDraft})
+
+	hits, total := ix.search("zapas", 0)
+	if total != 2 || hits[0].ID != "0001" {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+	if want := "Bizoni vyhráli <mark>zápas</mark> 3:2."; hits[0].Snippet != want {
+		t.Errorf("snippet = %q, want %q", hits[0].Snippet, want)
+	}
+	if !strings.Contains(hits[1].Snippet, "<mark>zápasu</mark>") || !strings.Contains(hits[1].Snippet, "&amp;") {
+		t.Errorf("snippet not highlighted or escaped: %q", hits[1].Snippet)
+	}
+	// Every query word must match
+	if hits, _ := ix.search("zápas trénink", 0); len(hits) != 1 || hits[0].ID != "0002" {
+		t.Errorf("multi-word query: %+v", hits)
+	}
+
+	// Edits and removals take effect without a rebuild
+	ix.update(&postRecord{ID: "0001", Title: "Turnaj", Body: "<p>Výsledky</p>", Status: postPublished})
+	ix.remove("0002")
+	if hits, _ := ix.search("zapas", 0); len(hits) != 0 {
+		t.Errorf("stale hits: %+v", hits)
+	}
+	if hits, _ := ix.search("VYSLEDKY", 0); len(hits) != 1 {
+		t.Errorf("edited post not found: %+v", hits)
+	}
+	if hits, _ := ix.search("vysl", 0); len(hits) != 1 {
+		t.Errorf("prefix not found: %+v", hits)
+	}
+
+	// The sorted vocabulary follows the postings
+	if !sort.StringsAreSorted(ix.terms) || len(ix.terms) != len(ix.postings) {
+		t.Fatalf("terms out of step: %v", ix.terms)
+	}
+	for _, term := range ix.terms {
+		if ix.postings[term] == nil {
+			t.Errorf("term %q has no postings", term)
+		}
+	}
+}
+
+func TestSnippetWindow(t *testing.T) {
+	text := strings.Repeat("slovo ", 100) + "gól " + strings.Repeat("text ", 100)
+	s, ok := snippet(text, []string{"gol"})
+	if !ok || !strings.HasPrefix(s, "…") || !strings.HasSuffix(s, "…") || !strings.Contains(s, "<mark>gól</mark>") {
+		t.Fatalf("unexpected snippet: %q", s)
+	}
+}
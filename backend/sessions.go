@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------- Admin sessions ----------------
+// Board members sign in at /admin/login and get an HttpOnly session cookie.
+// Sessions live in memory (a restart signs everyone out) and expire after
+// sessionIdleTimeout without requests or sessionMaxAge after login. Requests
+// that change data must echo the session's CSRF token in the X-CSRF-Token
+// header; admin pages read it from the bizoni_csrf cookie. Basic auth is only
+// accepted when ADMIN_BASIC_AUTH=1, for scripts.
+
+const (
+	sessionCookie      = "bizoni_session"
+	csrfCookie         = "bizoni_csrf"
+	csrfHeader         = "X-CSRF-Token"
+	sessionIdleTimeout = 2 * time.Hour
+	sessionMaxAge      = 12 * time.Hour
+)
+
+type adminSession struct {
+	User     string
+	CSRF     string
+	Created  time.Time
+	LastSeen time.Time
+}
+
+// expires returns when the session ends if no further request arrives
+func (s adminSession) expires() time.Time {
+	idle, max := s.LastSeen.Add(sessionIdleTimeout), s.Created.Add(sessionMaxAge)
+	if idle.Before(max) {
+		return idle
+	}
+	return max
+}
+
+type sessionStore struct {
+	mu     sync.Mutex
+	byHash map[[32]byte]*adminSession // sha256(cookie value) -> session
+}
+
+var sessions sessionStore
+
+// newToken returns 32 random bytes, base64url encoded
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// create starts a session for user and returns its cookie value
+func (s *sessionStore) create(user string, now time.Time) (string, adminSession, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", adminSession{}, err
+	}
+	csrf, err := newToken()
+	if err != nil {
+		return "", adminSession{}, err
+	}
+	sess := &adminSession{User: user, CSRF: csrf, Created: now, LastSeen: now}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byHash == nil {
+		s.byHash = make(map[[32]byte]*adminSession)
+	}
+	// Drop expired sessions whenever someone signs in
+	for k, old := range s.byHash {
+		if !now.Before(old.expires()) {
+			delete(s.byHash, k)
+		}
+	}
+	s.byHash[sha256.Sum256([]byte(token))] = sess
+	return token, *sess, nil
+}
+
+// lookup returns the live session for a cookie value and extends its idle
+// timeout
+func (s *sessionStore) lookup(token string, now time.Time) (adminSession, bool) {
+	key := sha256.Sum256([]byte(token))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.byHash[key]
+	if sess == nil {
+		return adminSession{}, false
+	}
+	if !now.Before(sess.expires()) {
+		delete(s.byHash, key)
+		return adminSession{}, false
+	}
+	sess.LastSeen = now
+	return *sess, true
+}
+
+// remove ends the session of a cookie value
+func (s *sessionStore) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byHash, sha256.Sum256([]byte(token)))
+}
+
+// basicAuthEnabled reports whether scripts may authenticate with Basic auth
+func basicAuthEnabled() bool {
+	return os.Getenv("ADMIN_BASIC_AUTH") == "1"
+}
+
+// unsafeMethod reports whether a request method may change data
+func unsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// crossSiteRequest reports whether a browser sent the request from another
+// site; scripts send neither Sec-Fetch-Site nor Origin
+func crossSiteRequest(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "cross-site", "same-site":
+		return true
+	}
+	if o := r.Header.Get("Origin"); o != "" {
+		u, err := url.Parse(o)
+		return err != nil || u.Host != r.Host
+	}
+	return false
+}
+
+// validCSRF checks a submitted CSRF token against the session's token
+func validCSRF(token string, sess adminSession) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(sess.CSRF)) == 1
+}
+
+// requestSession returns the live session a request carries
+func requestSession(r *http.Request) (adminSession, string, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return adminSession{}, "", false
+	}
+	sess, ok := sessions.lookup(c.Value, time.Now())
+	return sess, c.Value, ok
+}
+
+// unauthorized answers 401; the Basic auth challenge is only sent when Basic
+// auth is enabled so browsers do not pop up their login dialog
+func unauthorized(w http.ResponseWriter) {
+	if basicAuthEnabled() {
+		w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+	}
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// setSessionCookies sends the session cookie and the CSRF token cookie; an
+// empty token clears both
+func setSessionCookies(w http.ResponseWriter, token, csrf string) {
+	maxAge := int(sessionMaxAge / time.Second)
+	if token == "" {
+		maxAge = -1
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name: sessionCookie, Value: token, Path: "/", MaxAge: maxAge,
+		HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode,
+	})
+	// Readable by the admin pages, which copy it into the X-CSRF-Token header
+	http.SetCookie(w, &http.Cookie{
+		Name: csrfCookie, Value: csrf, Path: "/", MaxAge: maxAge,
+		Secure: true, SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// loginTarget returns the admin page to continue to after login
+func loginTarget(next string) string {
+	if strings.HasPrefix(next, "/admin/") && !strings.HasPrefix(next, "/admin/login") && !strings.ContainsAny(next, "\\\r\n") {
+		return next
+	}
+	return "/admin/"
+}
+
+// loginHandler serves the login page (GET) and signs in (POST with the form
+// fields name, password and next)
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if _, _, ok := requestSession(r); ok {
+			http.Redirect(w, r, loginTarget(r.URL.Query().Get("next")), http.StatusSeeOther)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeFile(w, r, filepath.Join(staticPath(), "admin", "login.html"))
+	case http.MethodPost:
+		// Login CSRF: only the login page itself may submit the form
+		if crossSiteRequest(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+		next := loginTarget(r.PostFormValue("next"))
+		name := strings.TrimSpace(r.PostFormValue("name"))
+		u, wait, ok := checkLogin(clientIP(r), name, r.PostFormValue("password"), "login")
+		if !ok {
+			q := url.Values{"error": {"1"}, "next": {next}}
+			if wait > 0 {
+				q = url.Values{"locked": {strconv.Itoa(retrySeconds(wait))}, "next": {next}}
+			}
+			http.Redirect(w, r, "/admin/login?"+q.Encode(), http.StatusSeeOther)
+			return
+		}
+		// Replace any session this browser had; every login gets fresh tokens
+		if _, old, ok := requestSession(r); ok {
+			sessions.remove(old)
+		}
+		token, sess, err := sessions.create(u.Name, time.Now())
+		if err != nil {
+			log.Printf("admin login: %v", err)
+			http.Error(w, "login failed", http.StatusInternalServerError)
+			return
+		}
+		setSessionCookies(w, token, sess.CSRF)
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// logoutHandler ends the session (POST with the CSRF token in the
+// X-CSRF-Token header or the csrf_token form field)
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if sess, cookie, ok := requestSession(r); ok {
+		token := r.Header.Get(csrfHeader)
+		if token == "" {
+			token = r.PostFormValue("csrf_token")
+		}
+		if !validCSRF(token, sess) {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		sessions.remove(cookie)
+	}
+	setSessionCookies(w, "", "")
+	http.Redirect(w, r, "/admin/login?logged_out=1", http.StatusSeeOther)
+}
+
+// sessionHandler tells the admin pages who is signed in
+func sessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	sess, _, ok := requestSession(r)
+	if !ok {
+		unauthorized(w)
+		return
+	}
+	u, ok := users.get(sess.User)
+	if !ok {
+		unauthorized(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(struct {
+		User      string    `json:"user"`
+		Role      string    `json:"role"`
+		CSRFToken string    `json:"csrf_token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{u.Name, u.Role, sess.CSRF, sess.expires()})
+}
+
+// adminPages lets signed-in accounts through to the admin pages and sends
+// everyone else to the login page
+func adminPages(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := authUser(r); !ok {
+			if basicAuthEnabled() && r.Header.Get("Authorization") != "" {
+				authFailed(w, r)
+				return
+			}
+			http.Redirect(w, r, "/admin/login?"+url.Values{"next": {r.URL.RequestURI()}}.Encode(), http.StatusSeeOther)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSessionLoginCSRFLogout walks through login, a mutating request with and
+// without the CSRF token, logout, and the opt-in Basic auth.
+func TestSessionLoginCSRFLogout(t *testing.T) {
+	if err := users.open(filepath.Join(t.TempDir(), "users.json")); err != nil {
+		t.Fatal(err)
+	}
+	defer users.open(filepath.Join(t.TempDir(), "none.json"))
+	if _, err := users.set("redaktor", roleEditor, "heslo-redaktora", true); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ADMIN_BASIC_AUTH", "")
+
+	login := func(pass string, header http.Header) *httptest.ResponseRecorder {
+		form := url.Values{"name": {"redaktor"}, "password": {pass}, "next": {"/admin/posts.html"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		loginHandler(rec, req)
+		return rec
+	}
+	if rec := login("spatne-heslo", nil); rec.Code != http.StatusSeeOther || !strings.Contains(rec.Header().Get("Location"), "error=1") || len(rec.Result().Cookies()) != 0 {
+		t.Fatalf("wrong password: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := login("heslo-redaktora", http.Header{"Origin": {"https://evil.example"}}); rec.Code != http.StatusForbidden {
+		t.Fatalf("cross-site login: %d", rec.Code)
+	}
+	rec := login("heslo-redaktora", nil)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/admin/posts.html" {
+		t.Fatalf("login: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	var session, csrf *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		switch c.Name {
+		case sessionCookie:
+			session = c
+		case csrfCookie:
+			csrf = c
+		}
+	}
+	if session == nil || csrf == nil {
+		t.Fatal("login set no cookies")
+	}
+	if !session.HttpOnly || !session.Secure || session.SameSite != http.SameSiteStrictMode || csrf.HttpOnly {
+		t.Errorf("cookie flags: %+v %+v", session, csrf)
+	}
+
+	mutate := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/blog/status", nil)
+		req.AddCookie(session)
+		if token != "" {
+			req.Header.Set(csrfHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		requireRole(rec, req, roleEditor)
+		return rec.Code
+	}
+	if code := mutate(""); code != http.StatusForbidden {
+		t.Errorf("without CSRF token: %d", code)
+	}
+	if code := mutate(csrf.Value); code != http.StatusOK {
+		t.Errorf("with CSRF token: %d", code)
+	}
+
+	// Server-side expiry
+	if _, ok := sessions.lookup(session.Value, time.Now().Add(sessionIdleTimeout+time.Minute)); ok {
+		t.Error("idle session still valid")
+	}
+	rec = login("heslo-redaktora", nil)
+	session = rec.Result().Cookies()[0]
+	csrf = rec.Result().Cookies()[1]
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/logout", strings.NewReader("csrf_token="+csrf.Value))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	out := httptest.NewRecorder()
+	logoutHandler(out, req)
+	if out.Code != http.StatusSeeOther {
+		t.Fatalf("logout: %d", out.Code)
+	}
+	if code := mutate(csrf.Value); code != http.StatusUnauthorized {
+		t.Errorf("after logout: %d", code)
+	}
+
+	// Basic auth works for scripts only when enabled
+	basic := func() int {
+		req := httptest.NewRequest(http.MethodDelete, "/api/blog/delete?id=0001", nil)
+		req.SetBasicAuth("redaktor", "heslo-redaktora")
+		rec := httptest.NewRecorder()
+		requireRole(rec, req, roleEditor)
+		return rec.Code
+	}
+	if code := basic(); code != http.StatusUnauthorized {
+		t.Errorf("basic auth while disabled: %d", code)
+	}
+	t.Setenv("ADMIN_BASIC_AUTH", "1")
+	if code := basic(); code != http.StatusOK {
+		t.Errorf("basic auth while enabled: %d", code)
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ---------------- Post shortcodes ----------------
+// Post bodies may contain shortcodes that are expanded when the page is
+// rendered, from the FACR club cache and the YouTube cache:
+//
+//	[match id="…"]           match card (teams, logos, score) by FACR match id
+//	[table competition="…"]  standings of a competition by id, code or name
+//	[video id="…"]           video card by YouTube id, with the cached title
+//	[media id="…"]           image (with srcset) or file link by asset id
+//	[gallery album="…"]      thumbnails of an album, linking to its gallery page
+//
+// A shortcode alone in a paragraph replaces the paragraph. Pages holding
+// shortcodes are rendered again after every successful cache refresh, so the
+// cards follow the data. Unknown ids render nothing on the public page and a
+// note in the admin preview.
+//
+// [media] and [gallery] read from the media library instead; pages are also
+// rendered again after every media change.
+
+//go:embed templates/shortcodes.html
+var shortcodesSrc string
+
+var shortcodeTemplates = template.Must(template.New("shortcodes").Parse(shortcodesSrc))
+
+var (
+	// The editors and the sanitizer write the quotes as entities
+	reShortcode      = regexp.MustCompile(`\[(match|table|video|media|gallery)\s+([^\[\]<>]*)\]`)
+	reShortcodeAlone = regexp.MustCompile(`^\s*(\[(?:match|table|video|media|gallery)\s+[^\[\]<>]*\])\s*$`)
+	reShortcodeAttr  = regexp.MustCompile(`([a-z_]+)\s*=\s*"([^"]*)"`)
+)
+
+// ourLogo is the logo refresh sets for our own club in matches and tables
+const ourLogo = "/img/logo.png"
+
+// matchCard is the data of a [match] card
+type matchCard struct {
+	Competition, MatchID, DateTime, Venue, Score, FacrLink string
+	Home, HomeLogoURL, Away, AwayLogoURL                   string
+}
+
+// tableCard is the data of a [table] card
+type tableCard struct {
+	Name string
+	Rows []tableCardRow
+}
+
+type tableCardRow struct {
+	Rank, Team, TeamLogo, Played, Wins, Draws, Losses, Score, Points string
+	Ours                                                             bool
+}
+
+// mediaCard is the data of a [media] card
+type mediaCard struct {
+	mediaAsset
+	JPEGSrcset, WebPSrcset string
+}
+
+// galleryCard is the data of a [gallery] card
+type galleryCard struct {
+	mediaAlbumInfo
+	Assets []mediaAsset
+}
+
+// hasShortcodes reports whether a post body contains a shortcode
+func hasShortcodes(body string) bool {
+	return reShortcode.MatchString(body)
+}
+
+// stripShortcodes removes shortcodes from body, for summaries and search
+func stripShortcodes(body string) string {
+	return reShortcode.ReplaceAllString(body, " ")
+}
+
+// expandShortcodes replaces the shortcodes of sanitized body HTML by their
+// cards. Only text outside <pre> and <code> is expanded; attribute values and
+// code samples keep their brackets.
+func expandShortcodes(body string, preview bool) string {
+	if !hasShortcodes(body) {
+		return body
+	}
+	type token struct {
+		typ html.TokenType
+		tag atom.Atom
+		raw string
+	}
+	var toks []token
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := string(z.Raw())
+		name, _ := z.TagName()
+		toks = append(toks, token{tt, atom.Lookup(name), raw})
+	}
+
+	var b strings.Builder
+	code := 0 // depth inside <pre> and <code>
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch t.typ {
+		case html.StartTagToken:
+			if t.tag == atom.Pre || t.tag == atom.Code {
+				code++
+			}
+			// A shortcode alone in a paragraph replaces the paragraph
+			if t.tag == atom.P && code == 0 && i+2 < len(toks) && toks[i+1].typ == html.TextToken &&
+				toks[i+2].typ == html.EndTagToken && toks[i+2].tag == atom.P {
+				if m := reShortcodeAlone.FindStringSubmatch(toks[i+1].raw); m != nil {
+					if card := renderShortcode(m[1], preview); card != m[1] {
+						b.WriteString(card)
+						i += 2
+						continue
+					}
+				}
+			}
+		case html.EndTagToken:
+			if (t.tag == atom.Pre || t.tag == atom.Code) && code > 0 {
+				code--
+			}
+		case html.TextToken:
+			if code == 0 {
+				b.WriteString(reShortcode.ReplaceAllStringFunc(t.raw, func(m string) string {
+					return renderShortcode(m, preview)
+				}))
+				continue
+			}
+		}
+		b.WriteString(t.raw)
+	}
+	return b.String()
+}
+
+// renderShortcode renders one shortcode; text that only looks like one, such
+// as "[match report]", is kept
+func renderShortcode(code string, preview bool) string {
+	m := reShortcode.FindStringSubmatch(code)
+	attrs := make(map[string]string)
+	for _, a := range reShortcodeAttr.FindAllStringSubmatch(html.UnescapeString(m[2]), -1) {
+		attrs[a[1]] = strings.TrimSpace(a[2])
+	}
+	if len(attrs) == 0 {
+		return code
+	}
+	name := m[1]
+	var (
+		data any
+		ok   bool
+	)
+	switch name {
+	case "match":
+		data, ok = findMatchCard(attrs["id"])
+	case "table":
+		data, ok = findTableCard(attrs["competition"])
+	case "video":
+		data, ok = findVideoCard(attrs["id"])
+	case "media":
+		data, ok = findMediaCard(attrs["id"])
+	case "gallery":
+		data, ok = findGalleryCard(attrs["album"])
+	}
+	if !ok {
+		if !preview {
+			return ""
+		}
+		name, data = "missing", "Shortcode "+html.UnescapeString(code)+": nenalezeno v datech"
+	}
+	var buf bytes.Buffer
+	if err := shortcodeTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("shortcode %s: %v", code, err)
+		return ""
+	}
+	return buf.String()
+}
+
+// findMatchCard looks a match up in the club cache by its FACR id
+func findMatchCard(id string) (matchCard, bool) {
+	if id == "" {
+		return matchCard{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, comp := range c.data.ClubDetail.Competitions {
+		for _, mt := range comp.Matches {
+			if mt.MatchID != id {
+				continue
+			}
+			return matchCard{
+				Competition: comp.Name, MatchID: mt.MatchID, DateTime: mt.DateTime, Venue: mt.Venue,
+				Score: mt.Score, FacrLink: mt.FacrLink,
+				Home: mt.Home, HomeLogoURL: mt.HomeLogoURL, Away: mt.Away, AwayLogoURL: mt.AwayLogoURL,
+			}, true
+		}
+	}
+	return matchCard{}, false
+}
+
+// findTableCard looks a competition's standings up by id, code or name
+func findTableCard(key string) (tableCard, bool) {
+	folded := foldCzech(key)
+	if folded == "" {
+		return tableCard{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, comp := range c.data.ClubTable.Competitions {
+		if comp.ID != key && !strings.EqualFold(comp.Code, key) && foldCzech(comp.Name) != folded {
+			continue
+		}
+		card := tableCard{Name: comp.Name}
+		for _, r := range comp.Table.Overall {
+			card.Rows = append(card.Rows, tableCardRow{
+				Rank: r.Rank, Team: r.Team, TeamLogo: r.TeamLogo, Played: r.Played, Wins: r.Wins,
+				Draws: r.Draws, Losses: r.Losses, Score: r.Score, Points: r.Points,
+				Ours: r.TeamLogo == ourLogo,
+			})
+		}
+		return card, len(card.Rows) > 0
+	}
+	return tableCard{}, false
+}
+
+// findVideoCard returns the cached video, or a plain card for any other valid
+// YouTube id (the cache only holds the newest videos)
+func findVideoCard(id string) (YTVideo, bool) {
+	id = youTubeID(id)
+	if id == "" {
+		return YTVideo{}, false
+	}
+	vc.mu.RLock()
+	defer vc.mu.RUnlock()
+	for _, v := range vc.data.Items {
+		if v.VideoID == id {
+			if v.ThumbnailURL == "" {
+				v.ThumbnailURL = "https://i.ytimg.com/vi/" + id + "/hqdefault.jpg"
+			}
+			return v, true
+		}
+	}
+	return YTVideo{VideoID: id, Title: "Video na YouTube", ThumbnailURL: "https://i.ytimg.com/vi/" + id + "/hqdefault.jpg"}, true
+}
+
+// findMediaCard returns the asset with its srcset lists
+func findMediaCard(id string) (mediaCard, bool) {
+	a, ok := media.get(id)
+	if !ok {
+		return mediaCard{}, false
+	}
+	card := mediaCard{mediaAsset: a}
+	var jpg, webp []string
+	for _, src := range a.Sources {
+		entry := src.URL + " " + strconv.Itoa(src.Width) + "w"
+		if src.Type == "image/webp" {
+			webp = append(webp, entry)
+		} else {
+			jpg = append(jpg, entry)
+		}
+	}
+	card.JPEGSrcset, card.WebPSrcset = strings.Join(jpg, ", "), strings.Join(webp, ", ")
+	return card, true
+}
+
+// findGalleryCard returns an album with its images
+func findGalleryCard(slug string) (galleryCard, bool) {
+	info, assets, ok := media.album(slug)
+	if !ok {
+		return galleryCard{}, false
+	}
+	card := galleryCard{mediaAlbumInfo: info}
+	for _, a := range assets {
+		if a.Kind == mediaImage {
+			card.Assets = append(card.Assets, a)
+		}
+	}
+	return card, len(card.Assets) > 0
+}
+
+// renderShortcodePages renders the pages of published posts with shortcodes
+// again, writing only those whose output changed
+func (s *postStore) renderShortcodePages() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, p := range s.byID {
+		// Pages without a layout are still the original hand-made HTML
+		if p.Status != postPublished || p.Layout == "" || !hasShortcodes(p.Body) {
+			continue
+		}
+		b, err := renderPage(p, false)
+		if err != nil {
+			return n, err
+		}
+		path := filepath.Join(s.blogDir, p.ID+".html")
+		if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, b) {
+			continue
+		}
+		if err := writeFileAtomic(path, b); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// refreshShortcodePages is called after the caches changed
+func refreshShortcodePages() {
+	n, err := posts.renderShortcodePages()
+	if err != nil {
+		log.Printf("warn: render shortcode pages: %v", err)
+	}
+	if n > 0 {
+		log.Printf("re-rendered %d post pages with shortcodes", n)
+	}
+}
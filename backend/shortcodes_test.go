@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setShortcodeCaches fills the club and video caches for a test
+func setShortcodeCaches(t *testing.T, score string) {
+	t.Helper()
+	var data Combined
+	err := json.Unmarshal([]byte(`{
+		"club_detail": {"competitions": [{"name": "Divize C", "matches": [
+			{"match_id": "M1", "home": "Bizoni UH", "home_logo_url": "/img/logo.png", "away": "Slavia <B>",
+			 "away_logo_url": "https://is.fotbal.cz/logo.png", "score": "`+score+`", "date_time": "12.10.2026 18:00",
+			 "venue": "Hala", "facr_link": "https://www.fotbal.cz/futsal/zapasy/futsal/M1"}]}]},
+		"club_table": {"competitions": [{"id": "C9", "code": "DIVC", "name": "Divize C", "table": {"overall": [
+			{"rank": "1", "team": "Bizoni UH", "team_logo_url": "/img/logo.png", "points": "30"},
+			{"rank": "2", "team": "Slavia B", "points": "25"}]}}]}}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	oldClub := c.data
+	c.data = data
+	c.mu.Unlock()
+	vc.mu.Lock()
+	oldVideos := vc.data.Items
+	vc.data.Items = []YTVideo{{VideoID: "dQw4w9WgXcQ", Title: "Sestřih zápasu", Length: "4:12"}}
+	vc.mu.Unlock()
+	t.Cleanup(func() {
+		c.mu.Lock()
+		c.data = oldClub
+		c.mu.Unlock()
+		vc.mu.Lock()
+		vc.data.Items = oldVideos
+		vc.mu.Unlock()
+	})
+}
+
+func TestExpandShortcodes(t *testing.T) {
+	setShortcodeCaches(t, "3:1")
+	body := sanitizeHTML(`<p>[match id="M1"]</p><p>Tabulka: [table competition="divize c"]</p>` +
+		`<p>[video id="dQw4w9WgXcQ"]</p><p>[video id="https://youtu.be/aaaaaaaaaaa"]</p>` +
+		`<p>[match id="X"]</p><p>[match report] zůstává</p>`)
+	got := expandShortcodes(body, false)
+	for _, want := range []string{
+		`<div class="sc-match" data-match="M1">`,
+		`<span class="sc-match-score">3:1</span>`,
+		`Slavia &lt;B&gt;`,
+		`<p>Tabulka: <div class="sc-table">`,
+		`<tr class="sc-table-ours"><td>1</td>`,
+		`Sestřih zápasu <span class="sc-video-length">4:12</span>`,
+		`href="https://www.youtube.com/watch?v=aaaaaaaaaaa"`,
+		`<p>[match report] zůstává</p>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<p><div") || strings.Contains(got, `id=&#34;X&#34;`) || strings.Contains(got, "sc-missing") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+	if preview := expandShortcodes(body, true); !strings.Contains(preview, `<p class="sc-missing">Shortcode [match id=&#34;X&#34;]`) {
+		t.Errorf("preview lacks the missing note:\n%s", preview)
+	}
+	if text := plainText(body); strings.Contains(text, "[") {
+		t.Errorf("shortcodes left in text: %q", text)
+	}
+
+	// Attribute values and code samples are not expanded
+	body = sanitizeHTML(`<p><a href="/blog/zapas" title='[match id="M1"]'>Zápas</a></p>` +
+		`<pre><code>[match id="M1"]</code></pre><p>Napište <code>[video id="dQw4w9WgXcQ"]</code>.</p>`)
+	got = expandShortcodes(body, false)
+	if got != body {
+		t.Errorf("expanded outside text:\n%s", got)
+	}
+}
+
+// TestShortcodePagesFollowCache verifies pages are rendered again when the
+// cached data changes
+func TestShortcodePagesFollowCache(t *testing.T) {
+	setShortcodeCaches(t, "")
+	blogDir := filepath.Join(t.TempDir(), "blog")
+	os.MkdirAll(blogDir, 0755)
+	var st postStore
+	if err := st.open(blogDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.put(postRecord{ID: "0001", Slug: "zapas", Title: "Zápas", ContentMode: contentVisual, Body: `<p>[match id="M1"]</p>`, Status: postPublished}); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(blogDir, "0001.html")
+	if b, _ := os.ReadFile(page); !strings.Contains(string(b), "–:–") {
+		t.Fatal("unplayed match not rendered")
+	}
+	if n, err := st.renderShortcodePages(); err != nil || n != 0 {
+		t.Errorf("unchanged page rewritten: %d, %v", n, err)
+	}
+	setShortcodeCaches(t, "5:2")
+	if n, err := st.renderShortcodePages(); err != nil || n != 1 {
+		t.Fatalf("expected 1 page, got %d, %v", n, err)
+	}
+	if b, _ := os.ReadFile(page); !strings.Contains(string(b), `<span class="sc-match-score">5:2</span>`) {
+		t.Error("page does not show the new score")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------- Sitemap and robots.txt ----------------
+// /sitemap.xml lists the static pages under STATIC_PATH, the match pages
+// (whose content follows the FACR data), the photo gallery with its albums
+// and every published post at its canonical URL. Once there are more URLs than one sitemap may hold it becomes
+// a sitemap index of /sitemaps/<n>.xml parts.
+
+// sitemapMaxURLs is the URL limit of a single sitemap file
+var sitemapMaxURLs = 50000
+
+// Directories under STATIC_PATH that hold no indexable pages of their own;
+// posts come from the post store instead of blog/
+var sitemapSkipDirs = map[string]bool{
+	"admin": true, "backend": true, "blog": true, "css": true, "data": true,
+	"img": true, "js": true, "templates": true, "tools": true, "node_modules": true,
+}
+
+// Error page and theme templates that are not site content; galerie.html
+// redirects to the gallery of the media library
+var sitemapSkipPages = map[string]bool{
+	"404.html": true, "blog-post.html": true, "football-match.html": true, "galerie.html": true,
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+	modified   time.Time
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+func newSitemapURL(loc string, modified time.Time, changeFreq, priority string) sitemapURL {
+	u := sitemapURL{Loc: loc, ChangeFreq: changeFreq, Priority: priority, modified: modified}
+	if !modified.IsZero() {
+		u.LastMod = modified.UTC().Format(time.RFC3339)
+	}
+	return u
+}
+
+// isMatchPage reports whether a site-relative page shows FACR match data
+func isMatchPage(rel string) bool {
+	return strings.HasPrefix(rel, "zapasy/") || rel == "football-matches.html"
+}
+
+// collectSitemap returns every URL of the site, pages first
+func collectSitemap(base string) ([]sitemapURL, error) {
+	site := staticPath()
+	c.mu.RLock()
+	matchesFetched := c.data.FetchedAt
+	c.mu.RUnlock()
+
+	var urls []sitemapURL
+	err := filepath.WalkDir(site, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(site, path)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if path != site && (strings.HasPrefix(d.Name(), ".") || sitemapSkipDirs[rel]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(rel, ".html") || sitemapSkipPages[rel] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		modified := info.ModTime()
+		switch {
+		case rel == "index.html":
+			urls = append(urls, newSitemapURL(base+"/", modified, "daily", "1.0"))
+		case isMatchPage(rel):
+			if matchesFetched.After(modified) {
+				modified = matchesFetched
+			}
+			urls = append(urls, newSitemapURL(base+"/"+rel, modified, "daily", "0.6"))
+		default:
+			urls = append(urls, newSitemapURL(base+"/"+rel, modified, "weekly", "0.8"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk site: %w", err)
+	}
+
+	// The gallery lists the albums that hold something, as its page does
+	var galleryModified time.Time
+	var albums []sitemapURL
+	for _, al := range media.albumList() {
+		if al.Count == 0 {
+			continue
+		}
+		modified := al.CreatedAt
+		if _, assets, ok := media.album(al.Slug); ok {
+			for _, a := range assets {
+				if a.CreatedAt.After(modified) {
+					modified = a.CreatedAt
+				}
+			}
+		}
+		if modified.After(galleryModified) {
+			galleryModified = modified
+		}
+		albums = append(albums, newSitemapURL(base+"/galerie/"+al.Slug, modified, "weekly", "0.6"))
+	}
+	urls = append(urls, newSitemapURL(base+"/galerie/", galleryModified, "weekly", "0.8"))
+	urls = append(urls, albums...)
+
+	st, err := blogStoreFor(site)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range st.query(postQuery{}) {
+		urls = append(urls, newSitemapURL(base+it.Link, it.MTime, "monthly", "0.7"))
+	}
+	return urls, nil
+}
+
+// lastModified returns the newest modification time of urls
+func lastModified(urls []sitemapURL) time.Time {
+	var t time.Time
+	for _, u := range urls {
+		if u.modified.After(t) {
+			t = u.modified
+		}
+	}
+	return t
+}
+
+// sitemapHandler serves /sitemap.xml and its /sitemaps/<n>.xml parts
+func sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	base := siteBaseURL()
+	urls, err := collectSitemap(base)
+	if err != nil {
+		log.Printf("sitemap: %v", err)
+		http.Error(w, "sitemap unavailable", http.StatusInternalServerError)
+		return
+	}
+	parts := (len(urls) + sitemapMaxURLs - 1) / sitemapMaxURLs
+	part := func(n int) []sitemapURL {
+		start := (n - 1) * sitemapMaxURLs
+		return urls[start:min(start+sitemapMaxURLs, len(urls))]
+	}
+
+	var doc any
+	var modified time.Time
+	if strings.HasPrefix(r.URL.Path, "/sitemaps/") {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/sitemaps/"), ".xml"))
+		if err != nil || n < 1 || n > parts || parts < 2 || !strings.HasSuffix(r.URL.Path, ".xml") {
+			http.NotFound(w, r)
+			return
+		}
+		doc = sitemapURLSet{URLs: part(n)}
+		modified = lastModified(part(n))
+	} else if parts > 1 {
+		idx := sitemapIndex{}
+		for n := 1; n <= parts; n++ {
+			ref := sitemapRef{Loc: fmt.Sprintf("%s/sitemaps/%d.xml", base, n)}
+			if t := lastModified(part(n)); !t.IsZero() {
+				ref.LastMod = t.UTC().Format(time.RFC3339)
+			}
+			idx.Sitemaps = append(idx.Sitemaps, ref)
+		}
+		doc = idx
+		modified = lastModified(urls)
+	} else {
+		doc = sitemapURLSet{URLs: urls}
+		modified = lastModified(urls)
+	}
+	body, err := marshalXML(doc)
+	if err != nil {
+		log.Printf("sitemap: %v", err)
+		http.Error(w, "sitemap unavailable", http.StatusInternalServerError)
+		return
+	}
+	serveGenerated(w, r, "application/xml; charset=utf-8", body, modified)
+}
+
+// robotsHandler serves a robots.txt that keeps crawlers out of the admin and
+// API and points them at the sitemap
+func robotsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "User-agent: *\nDisallow: /admin/\nDisallow: /api/\nDisallow: /data/\n\nSitemap: %s/sitemap.xml\n", siteBaseURL())
+}
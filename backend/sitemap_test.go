@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSitemap verifies pages, posts and the switch to a sitemap index.
+func TestSitemap(t *testing.T) {
+	site := t.TempDir()
+	for _, name := range []string{"index.html", "kontakt.html", "404.html", "galerie.html", "zapasy/vsechny.html", "admin/new.html"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(site, name)), 0755)
+		os.WriteFile(filepath.Join(site, name), []byte("<html></html>"), 0644)
+	}
+	os.MkdirAll(filepath.Join(site, "blog"), 0755)
+	os.WriteFile(filepath.Join(site, "blog", "0001.html"), []byte(`<html><head><meta name="slug" content="vyhra"></head><body><h1 class="lte-header">Výhra</h1></body></html>`), 0644)
+	t.Setenv("STATIC_PATH", site)
+	t.Setenv("REMOTE_BLOG_DIR", "")
+	// Without SITE_URL the canonical address is used, never the request's host
+	t.Setenv("SITE_URL", "")
+	useMediaLibrary(t)
+	if _, err := media.saveAlbum(mediaAlbum{Title: "Prázdné"}); err != nil {
+		t.Fatal(err)
+	}
+	al, _ := media.saveAlbum(mediaAlbum{Title: "Trénink"})
+	if _, err := media.add("trenink.png", encodePNG(t, halves(400, 300)).Bytes(), "", []string{al.Slug}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) string {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = "evil.example"
+		sitemapHandler(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", path, rec.Code)
+		}
+		return rec.Body.String()
+	}
+	body := get("/sitemap.xml")
+	for _, want := range []string{
+		"<loc>https://www.bizoniuh.cz/</loc>",
+		"<loc>https://www.bizoniuh.cz/kontakt.html</loc>",
+		"<loc>https://www.bizoniuh.cz/zapasy/vsechny.html</loc>",
+		"<loc>https://www.bizoniuh.cz/blog/vyhra</loc>",
+		"<loc>https://www.bizoniuh.cz/galerie/</loc>",
+		"<loc>https://www.bizoniuh.cz/galerie/trenink</loc>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("sitemap missing %s", want)
+		}
+	}
+	for _, unwanted := range []string{"404.html", "admin", "index.html", "0001.html", "galerie.html", "prazdne"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("sitemap lists %s", unwanted)
+		}
+	}
+
+	defer func(n int) { sitemapMaxURLs = n }(sitemapMaxURLs)
+	sitemapMaxURLs = 2
+	if idx := get("/sitemap.xml"); !strings.Contains(idx, "<sitemapindex") || !strings.Contains(idx, "<loc>https://www.bizoniuh.cz/sitemaps/2.xml</loc>") {
+		t.Fatalf("expected sitemap index, got:\n%s", idx)
+	}
+	if part := get("/sitemaps/2.xml"); strings.Count(part, "<url>") != 2 {
+		t.Errorf("unexpected part:\n%s", part)
+	}
+	rec := httptest.NewRecorder()
+	sitemapHandler(rec, httptest.NewRequest(http.MethodGet, "/sitemaps/4.xml", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing part: got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/robots.txt", nil)
+	req.Host = "evil.example"
+	robotsHandler(rec, req)
+	if !strings.Contains(rec.Body.String(), "Sitemap: https://www.bizoniuh.cz/sitemap.xml") {
+		t.Errorf("robots.txt:\n%s", rec.Body)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ---------------- Admin accounts ----------------
+// Every board member has an own account in users.json on the data volume:
+// a bcrypt password hash and one of three roles. Viewers can read the admin
+// API, editors can also write posts, admins can do everything. Accounts are
+// managed with `server user ...`; the server refuses to start without one.
+
+const (
+	roleViewer = "viewer"
+	roleEditor = "editor"
+	roleAdmin  = "admin"
+)
+
+var roleRank = map[string]int{roleViewer: 1, roleEditor: 2, roleAdmin: 3}
+
+func validRole(role string) bool { return roleRank[role] > 0 }
+
+// minPasswordLength applies to passwords set through the user command
+const minPasswordLength = 10
+
+// leakedDefaultPassword was the hardcoded fallback password of earlier
+// versions; it is public, so no account may use it
+const leakedDefaultPassword = "%8s3Yad*!b3*t"
+
+var reUserName = regexp.MustCompile(`^[A-Za-z0-9._@+-]{2,64}$`)
+
+type adminUser struct {
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Hash      string    `json:"hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type userStore struct {
+	mu     sync.RWMutex
+	path   string
+	byName map[string]*adminUser
+}
+
+var users userStore
+
+// dummyHash keeps logins with unknown names as slow as those with known ones
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+func usersPath() string {
+	if p := os.Getenv("USERS_PATH"); p != "" {
+		return p
+	}
+	// Default: next to club.json on the data volume
+	return filepath.Join(filepath.Dir(dataPath()), "users.json")
+}
+
+// open loads the accounts stored at path (none if the file does not exist)
+func (s *userStore) open(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	s.byName = make(map[string]*adminUser)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var payload struct {
+		Users []*adminUser `json:"users"`
+	}
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return fmt.Errorf("unmarshal users: %w", err)
+	}
+	for _, u := range payload.Users {
+		s.byName[u.Name] = u
+	}
+	return nil
+}
+
+// checkStartup makes sure the server does not run on shared default
+// credentials. Without accounts, ADMIN_USER/ADMIN_PASS create the first admin.
+func (s *userStore) checkStartup() error {
+	if s.count() == 0 {
+		name, pass := os.Getenv("ADMIN_USER"), os.Getenv("ADMIN_PASS")
+		if name == "" || pass == "" {
+			return fmt.Errorf("no admin accounts in %s: create one with `server user add <name> admin` or set ADMIN_USER and ADMIN_PASS", s.path)
+		}
+		if pass == leakedDefaultPassword {
+			return fmt.Errorf("ADMIN_PASS is the former built-in default password; choose another one")
+		}
+		if _, err := s.set(name, roleAdmin, pass, true); err != nil {
+			return fmt.Errorf("create admin from ADMIN_USER: %w", err)
+		}
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.byName {
+		if bcrypt.CompareHashAndPassword([]byte(u.Hash), []byte(leakedDefaultPassword)) == nil {
+			return fmt.Errorf("account %q uses the former built-in default password; reset it with `server user reset %s`", u.Name, u.Name)
+		}
+	}
+	return nil
+}
+
+func (s *userStore) count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.byName)
+}
+
+// set creates an account or, if it exists and create is false, resets its
+// password; an empty role keeps the current one. It reports whether the
+// account was created.
+func (s *userStore) set(name, role, password string, create bool) (bool, error) {
+	if !reUserName.MatchString(name) {
+		return false, fmt.Errorf("invalid user name %q", name)
+	}
+	if role != "" && !validRole(role) {
+		return false, fmt.Errorf("invalid role %q (viewer, editor, admin)", role)
+	}
+	if len(password) < minPasswordLength {
+		return false, fmt.Errorf("password must have at least %d characters", minPasswordLength)
+	}
+	if password == leakedDefaultPassword {
+		return false, fmt.Errorf("this password was published as a default; choose another one")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	u := s.byName[name]
+	created := u == nil
+	switch {
+	case created && role == "":
+		return false, fmt.Errorf("role required for new user %q", name)
+	case created:
+		u = &adminUser{Name: name, CreatedAt: now}
+		s.byName[name] = u
+	case create:
+		return false, fmt.Errorf("user %q already exists", name)
+	}
+	if role != "" {
+		u.Role = role
+	}
+	u.Hash = string(hash)
+	u.UpdatedAt = now
+	return created, s.saveLocked()
+}
+
+// setRole changes the role of an existing account
+func (s *userStore) setRole(name, role string) error {
+	if !validRole(role) {
+		return fmt.Errorf("invalid role %q (viewer, editor, admin)", role)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.byName[name]
+	if u == nil {
+		return os.ErrNotExist
+	}
+	u.Role = role
+	u.UpdatedAt = time.Now()
+	return s.saveLocked()
+}
+
+// remove deletes an account
+func (s *userStore) remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byName[name] == nil {
+		return os.ErrNotExist
+	}
+	delete(s.byName, name)
+	return s.saveLocked()
+}
+
+// get returns an account without its password hash
+func (s *userStore) get(name string) (adminUser, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u := s.byName[name]
+	if u == nil {
+		return adminUser{}, false
+	}
+	out := *u
+	out.Hash = ""
+	return out, true
+}
+
+// list returns all accounts without password hashes, sorted by name
+func (s *userStore) list() []adminUser {
+	s.mu.RLock()
+	out := make([]adminUser, 0, len(s.byName))
+	for _, u := range s.byName {
+		c := *u
+		c.Hash = ""
+		out = append(out, c)
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// authenticate checks a name and password and returns the account
+func (s *userStore) authenticate(name, password string) (adminUser, bool) {
+	s.mu.RLock()
+	u := s.byName[name]
+	hash := dummyHash
+	if u != nil {
+		hash = []byte(u.Hash)
+	}
+	s.mu.RUnlock()
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || u == nil {
+		return adminUser{}, false
+	}
+	return s.get(name)
+}
+
+// saveLocked persists the accounts atomically; callers hold s.mu
+func (s *userStore) saveLocked() error {
+	list := make([]*adminUser, 0, len(s.byName))
+	for _, u := range s.byName {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	b, err := json.MarshalIndent(struct {
+		Users []*adminUser `json:"users"`
+	}{Users: list}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	if err := writeFileAtomic(s.path, b); err != nil {
+		return err
+	}
+	// The file holds password hashes; keep it private to the server user
+	return os.Chmod(s.path, 0600)
+}
+
+// ---- Request authentication ----
+
+// authUser returns the account a request authenticates as, either through
+// its session cookie or, when enabled, Basic auth. The session is nil for
+// Basic auth.
+func authUser(r *http.Request) (adminUser, *adminSession, bool) {
+	if sess, _, ok := requestSession(r); ok {
+		// Look the account up again so removals and role changes apply at once
+		u, ok := users.get(sess.User)
+		return u, &sess, ok
+	}
+	if !basicAuthEnabled() {
+		return adminUser{}, nil, false
+	}
+	name, pass, ok := r.BasicAuth()
+	if !ok {
+		return adminUser{}, nil, false
+	}
+	u, _, ok := checkLogin(clientIP(r), name, pass, "basic auth")
+	return u, nil, ok
+}
+
+// authFailed answers a request that did not authenticate: 429 while its Basic
+// auth credentials are locked out, 401 otherwise
+func authFailed(w http.ResponseWriter, r *http.Request) {
+	if name, _, ok := r.BasicAuth(); ok && basicAuthEnabled() {
+		if wait := loginWait(clientIP(r), name, time.Now()); wait > 0 {
+			tooManyAttempts(w, wait)
+			return
+		}
+	}
+	unauthorized(w)
+}
+
+// requestUserKey keys the account requireRole resolved in the request context
+type requestUserKey struct{}
+
+// requestUser returns the name of the account a request was let through
+// with; it reads what requireRole stored and never authenticates again
+func requestUser(r *http.Request) string {
+	u, _ := r.Context().Value(requestUserKey{}).(adminUser)
+	return u.Name
+}
+
+// requireRole lets the request through if it authenticates as an account
+// with at least role and, for requests that change data, carries the
+// session's CSRF token; otherwise it answers 401 or 403 and returns false
+func requireRole(w http.ResponseWriter, r *http.Request, role string) bool {
+	u, sess, ok := authUser(r)
+	if !ok {
+		authFailed(w, r)
+		return false
+	}
+	if unsafeMethod(r.Method) {
+		if sess != nil && !validCSRF(r.Header.Get(csrfHeader), *sess) {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return false
+		}
+		// Browsers may replay cached Basic credentials on cross-site requests
+		if sess == nil && crossSiteRequest(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return false
+		}
+	}
+	if roleRank[u.Role] < roleRank[role] {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	// Handlers keep using r, so the account is stored in it in place
+	*r = *r.WithContext(context.WithValue(r.Context(), requestUserKey{}, u))
+	return true
+}
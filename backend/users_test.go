@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// TestUserStoreRolesAndStartup verifies hashed accounts, role checks and the
+// refusal to start without accounts or on the former default password.
+func TestUserStoreRolesAndStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	t.Setenv("ADMIN_USER", "")
+	t.Setenv("ADMIN_PASS", "")
+	var st userStore
+	if err := st.open(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.checkStartup(); err == nil {
+		t.Fatal("started without accounts")
+	}
+	t.Setenv("ADMIN_USER", "predseda")
+	t.Setenv("ADMIN_PASS", leakedDefaultPassword)
+	if err := st.checkStartup(); err == nil {
+		t.Fatal("started with the former default password")
+	}
+	t.Setenv("ADMIN_PASS", "silne-heslo-2024")
+	if err := st.checkStartup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.set("redaktor", roleEditor, "heslo-redaktora", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.set("redaktor", roleEditor, "jine-heslo-123", true); err == nil {
+		t.Fatal("add overwrote an existing account")
+	}
+
+	// The global store is what requireRole checks against
+	if err := users.open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer users.open(filepath.Join(t.TempDir(), "none.json"))
+	if u, ok := users.authenticate("predseda", "silne-heslo-2024"); !ok || u.Role != roleAdmin || u.Hash != "" {
+		t.Fatalf("bootstrap admin: %+v %v", u, ok)
+	}
+	if _, ok := users.authenticate("redaktor", "spatne-heslo"); ok {
+		t.Fatal("wrong password accepted")
+	}
+
+	t.Setenv("ADMIN_BASIC_AUTH", "1")
+	check := func(name, pass, role string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/blog/posts", nil)
+		req.SetBasicAuth(name, pass)
+		rec := httptest.NewRecorder()
+		requireRole(rec, req, role)
+		return rec.Code
+	}
+	if code := check("redaktor", "heslo-redaktora", roleEditor); code != http.StatusOK {
+		t.Errorf("editor as editor: %d", code)
+	}
+	if code := check("redaktor", "heslo-redaktora", roleAdmin); code != http.StatusForbidden {
+		t.Errorf("editor as admin: %d", code)
+	}
+	if code := check("redaktor", "x", roleViewer); code != http.StatusUnauthorized {
+		t.Errorf("bad password: %d", code)
+	}
+
+	// requireRole stores the account; requestUser reads it without checking
+	// the password again
+	req := httptest.NewRequest(http.MethodGet, "/api/blog/posts", nil)
+	req.SetBasicAuth("redaktor", "heslo-redaktora")
+	if name := requestUser(req); name != "" {
+		t.Errorf("user before requireRole: %q", name)
+	}
+	if !requireRole(httptest.NewRecorder(), req, roleViewer) {
+		t.Fatal("editor refused")
+	}
+
+	if _, err := users.set("redaktor", "", "nove-heslo-2025", false); err != nil {
+		t.Fatal(err)
+	}
+	if name := requestUser(req); name != "redaktor" {
+		t.Errorf("user after requireRole: %q", name)
+	}
+	if code := check("redaktor", "heslo-redaktora", roleViewer); code != http.StatusUnauthorized {
+		t.Errorf("old password after change: %d", code)
+	}
+}
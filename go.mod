@@ -1,3 +1,14 @@
 module bizoni-backend
 
-go 1.22
+go 1.22.2
+
+require golang.org/x/crypto v0.33.0
+
+require golang.org/x/net v0.34.0
+
+require (
+	github.com/HugoSmits86/nativewebp v1.2.1
+	github.com/yuin/goldmark v1.7.8
+)
+
+require golang.org/x/image v0.24.0
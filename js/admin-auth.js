@@ -1,69 +1,73 @@
 'use strict';
 (function(){
-  const KEY = 'adminAuthB64'; // stores base64 of user:pass
+  // The session lives in an HttpOnly cookie set by /admin/login. Requests that
+  // change data must send the session's CSRF token, which the server also puts
+  // into the readable bizoni_csrf cookie.
+  const CSRF_COOKIE = 'bizoni_csrf';
 
-  function b64(u, p){
-    try { return btoa(`${u}:${p}`); } catch { return ''; }
-  }
+  // Older versions kept base64 user:pass in localStorage – remove it
+  try { localStorage.removeItem('adminAuthB64'); } catch {}
 
-  function get(){
-    try { return localStorage.getItem(KEY) || ''; } catch { return ''; }
-  }
-  function set(val){
-    try { localStorage.setItem(KEY, val||''); } catch {}
+  function csrfToken(){
+    const m = document.cookie.match(new RegExp('(?:^|;\\s*)' + CSRF_COOKIE + '=([^;]*)'));
+    return m ? decodeURIComponent(m[1]) : '';
   }
-  function clear(){
-    try { localStorage.removeItem(KEY); } catch {}
+
+  function toLogin(){
+    location.href = '/admin/login?next=' + encodeURIComponent(location.pathname + location.search);
   }
 
   window.AdminAuth = {
-    has(){ return !!get(); },
-    getHeaders(){ const v = get(); return v ? { 'Authorization': 'Basic '+v } : {}; },
-    setCreds(user, pass){ set(b64(user, pass)); },
-    clear(){ clear(); }
+    has(){ return !!csrfToken(); },
+    getHeaders(){ const t = csrfToken(); return t ? { 'X-CSRF-Token': t } : {}; },
+    csrfToken,
+    toLogin
   };
 
-  // small UI helper (optional) – appears bottom-left
-  function ensureWidget(){
+  // small UI helper – signed-in user and logout, bottom-left
+  async function ensureWidget(){
     if (document.getElementById('admin-auth-widget')) return;
-    const wrap = document.createElement('div');
+    const wrap = document.createElement('form');
     wrap.id = 'admin-auth-widget';
+    wrap.method = 'post';
+    wrap.action = '/admin/logout';
     wrap.style.position = 'fixed';
     wrap.style.left = '12px';
     wrap.style.bottom = '12px';
     wrap.style.zIndex = '9999';
     wrap.style.display = 'flex';
     wrap.style.gap = '6px';
+    wrap.style.alignItems = 'center';
 
-    const btnSet = document.createElement('button');
-    btnSet.textContent = 'Přihlásit';
-    btnSet.style.padding = '6px 10px';
-    btnSet.style.borderRadius = '8px';
-    btnSet.style.border = '1px solid #cbd5e1';
-    btnSet.style.background = '#fff';
-    btnSet.addEventListener('click', () => {
-      const u = prompt('Uživatel (e-mail):');
-      if (!u) return;
-      const p = prompt('Heslo:');
-      if (p == null) return;
-      window.AdminAuth.setCreds(u, p);
-      alert('Přihlašovací údaje uloženy do tohoto prohlížeče.');
-    });
+    const who = document.createElement('span');
+    who.style.fontSize = '12px';
+    who.style.color = '#94a3b8';
 
-    const btnClr = document.createElement('button');
-    btnClr.textContent = 'Odhlásit';
-    btnClr.style.padding = '6px 10px';
-    btnClr.style.borderRadius = '8px';
-    btnClr.style.border = '1px solid #cbd5e1';
-    btnClr.style.background = '#fff';
-    btnClr.addEventListener('click', () => {
-      window.AdminAuth.clear();
-      alert('Odhlášeno – uložené údaje odstraněny.');
-    });
+    const token = document.createElement('input');
+    token.type = 'hidden';
+    token.name = 'csrf_token';
 
-    wrap.appendChild(btnSet);
-    wrap.appendChild(btnClr);
+    const btnOut = document.createElement('button');
+    btnOut.type = 'submit';
+    btnOut.textContent = 'Odhlásit';
+    btnOut.style.padding = '6px 10px';
+    btnOut.style.borderRadius = '8px';
+    btnOut.style.border = '1px solid #cbd5e1';
+    btnOut.style.background = '#fff';
+    wrap.addEventListener('submit', () => { token.value = csrfToken(); });
+
+    wrap.appendChild(btnOut);
+    wrap.appendChild(who);
+    wrap.appendChild(token);
     document.body.appendChild(wrap);
+
+    try {
+      const res = await fetch('/admin/session', { cache: 'no-store' });
+      if (res.status === 401) { toLogin(); return; }
+      if (!res.ok) return;
+      const s = await res.json();
+      who.textContent = s.user + ' (' + s.role + ')';
+    } catch {}
   }
 
   if (document.readyState === 'loading') {
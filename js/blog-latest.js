@@ -9,13 +9,28 @@
     return e;
   }
 
+  // Card image with srcset from the responsive variants (item.sources); WebP
+  // variants go into a <source> so browsers without WebP use the JPEGs
+  function cardImage(item, attrs){
+    const img = h('img', Object.assign({src: item.image}, attrs));
+    const sources = Array.isArray(item.sources) ? item.sources : [];
+    const srcset = type => sources.filter(s => s.type === type).map(s => s.url + ' ' + s.width + 'w').join(', ');
+    const jpeg = srcset('image/jpeg');
+    if (!jpeg) return img;
+    img.setAttribute('srcset', jpeg);
+    img.setAttribute('sizes', attrs.sizes);
+    const webp = srcset('image/webp');
+    if (!webp) return img;
+    return h('picture', {}, [h('source', {type: 'image/webp', srcset: webp, sizes: attrs.sizes}), img]);
+  }
+
   function renderItem(item){
     const col = h('div', {class: 'items col-xl-6 col-lg-6 col-md-6 col-sm-6 col-ms-6 col-xs-12'});
     const article = h('article', {class: 'post-25620 post type-post status-publish format-standard has-post-thumbnail hentry'});
 
     const aPhoto = h('a', {href: item.link, class: 'lte-photo'});
-    const img = h('img', {
-      src: item.image,
+    const img = cardImage(item, {
+      sizes: '(min-width: 768px) 50vw, 100vw',
       width: '500',
       height: '300',
       decoding: 'async',
@@ -9,12 +9,27 @@
     return e;
   }
 
+  // Card image with srcset from the responsive variants (item.sources); WebP
+  // variants go into a <source> so browsers without WebP use the JPEGs
+  function cardImage(item, attrs){
+    const img = h('img', Object.assign({src: item.image}, attrs));
+    const sources = Array.isArray(item.sources) ? item.sources : [];
+    const srcset = type => sources.filter(s => s.type === type).map(s => s.url + ' ' + s.width + 'w').join(', ');
+    const jpeg = srcset('image/jpeg');
+    if (!jpeg) return img;
+    img.setAttribute('srcset', jpeg);
+    img.setAttribute('sizes', attrs.sizes);
+    const webp = srcset('image/webp');
+    if (!webp) return img;
+    return h('picture', {}, [h('source', {type: 'image/webp', srcset: webp, sizes: attrs.sizes}), img]);
+  }
+
   function renderItem(item){
     const col = h('div', {class: 'col-xl-4 col-lg-6 col-md-6 col-sm-12 col-xs-12 item div-thumbnail'});
     const article = h('article', {class: 'post-25620 post type-post status-publish format-standard has-post-thumbnail hentry'});
     const aPhoto = h('a', {href: item.link, class: 'lte-photo'});
-    const img = h('img', {
-      src: item.image,
+    const img = cardImage(item, {
+      sizes: '(min-width: 1200px) 33vw, (min-width: 768px) 50vw, 100vw',
       width: '500', height: '300', decoding: 'async', fetchpriority: 'high',
       class: 'attachment-atleticos-blog size-atleticos-blog wp-post-image', alt: ''
     });
@@ -30,47 +45,64 @@
     return col;
   }
 
-  function numericDesc(a,b){
-    const ai = parseInt(a.id,10); const bi = parseInt(b.id,10);
-    if (!isNaN(ai) && !isNaN(bi)) return bi - ai;
-    return (b.id||'').localeCompare(a.id||'');
-  }
-
   function getQueryParam(name){
     const url = new URL(window.location.href);
     return url.searchParams.get(name);
   }
 
-  function normalize(s){ return (s||'').toString().trim().toLowerCase(); }
+  const PER_PAGE = 12;
+
+  async function fetchPage(page){
+    const params = new URLSearchParams({page: String(page), per_page: String(PER_PAGE)});
+    // Optional filter by category via ?category=XYZ
+    const qCat = getQueryParam('category');
+    if (qCat) params.set('category', qCat);
+    const res = await fetch('/api/blog/latest?' + params.toString(), {credentials: 'omit'});
+    if (!res.ok) throw new Error('HTTP '+res.status);
+    return res.json();
+  }
 
   async function loadAll(){
     // Render into the primary masonry grid, overwriting any static items
     const mount = document.querySelector('.lte-blog-wrap .blog .row.masonry');
     if (!mount) return;
     mount.innerHTML = '<div style="width:100%;text-align:center;padding:12px;color:#888;">Načítání…</div>';
-    try {
-      const res = await fetch('/api/blog/latest?limit=10000', {credentials: 'omit'});
-      if (!res.ok) throw new Error('HTTP '+res.status);
-      let items = await res.json();
-      if (!Array.isArray(items)) items = [];
-      // Sort by numeric ID desc to ensure largest number is latest
-      items.sort(numericDesc);
-
-      // Optional filter by category via ?category=XYZ
-      const qCat = getQueryParam('category');
-      if (qCat) {
-        const want = normalize(qCat);
-        items = items.filter(it => Array.isArray(it.categories) && it.categories.some(c => normalize(c) === want));
-      }
-
-      mount.innerHTML = '';
-      if (items.length === 0) {
-        mount.innerHTML = '<div style="width:100%;text-align:center;padding:12px;color:#888;">Žádné příspěvky zatím nejsou.</div>';
-        return;
+    let page = 1;
+    let more = null;
+    async function loadPage(){
+      const data = await fetchPage(page);
+      const items = Array.isArray(data.items) ? data.items : [];
+      if (page === 1) {
+        mount.innerHTML = '';
+        if (items.length === 0) {
+          mount.innerHTML = '<div style="width:100%;text-align:center;padding:12px;color:#888;">Žádné příspěvky zatím nejsou.</div>';
+          return;
+        }
       }
       const frag = document.createDocumentFragment();
       items.forEach(it => frag.appendChild(renderItem(it)));
       mount.appendChild(frag);
+      // "Load more" button below the grid while pages remain
+      if (page < (data.pages || 1)) {
+        if (!more) {
+          const btn = h('button', {type: 'button', class: 'lte-btn'});
+          btn.textContent = 'Načíst další';
+          btn.addEventListener('click', async () => {
+            btn.disabled = true;
+            page++;
+            try { await loadPage(); } catch (e) { console.error('Load blog page error', e); page--; }
+            btn.disabled = false;
+          });
+          more = h('div', {style: 'width:100%;text-align:center;padding:12px;'}, [btn]);
+          mount.parentNode.appendChild(more);
+        }
+      } else if (more) {
+        more.remove();
+        more = null;
+      }
+    }
+    try {
+      await loadPage();
     } catch (e) {
       console.error('Load blog list error', e);
       mount.innerHTML = '<div style="width:100%;text-align:center;padding:12px;color:#c00;">Nepodařilo se načíst seznam článků.</div>';
@@ -45,7 +45,10 @@
         if (it && it.id) usedIds.push(it.id);
         const slide = slides[i+1];
         if (!slide) continue;
-        slide.style.backgroundImage = `url('${it.image}')`;
+        // Largest JPEG variant when the post has them; the PNG is much bigger
+        const jpegs = (it.sources || []).filter(s => s.type === 'image/jpeg');
+        const bg = jpegs.length ? jpegs[jpegs.length - 1].url : it.image;
+        slide.style.backgroundImage = `url('${bg}')`;
         const content = document.querySelector(`.lte-zs-slider-inner.lte-zs-slide-${i+1}`);
         if (content) {
           const header = content.querySelector('h2.lte-header');